@@ -1,7 +1,9 @@
 package consts
 
 const (
-	ReqId = "reqId"
+	ReqId    = "reqId"
+	UserId   = "userId"
+	TenantId = "tenantId"
 )
 
 const (
@@ -0,0 +1,86 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Exporter 输出结束的 span，需并发安全，可对接 OTLP、Jaeger 等
+type Exporter interface {
+	Export(s SpanData)
+}
+
+var (
+	exporter     Exporter
+	exporterLock sync.RWMutex
+)
+
+// SetExporter 设置 Exporter，nil 时关闭追踪
+func SetExporter(e Exporter) {
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+	exporter = e
+}
+
+func getExporter() Exporter {
+	exporterLock.RLock()
+	defer exporterLock.RUnlock()
+	return exporter
+}
+
+// Enabled 是否开启追踪
+func Enabled() bool {
+	return getExporter() != nil
+}
+
+// WriterExporter 每个 span 输出一行 JSON，用于本地调试
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+	c  io.Closer
+}
+
+// NewWriterExporter 输出到 w，如 os.Stdout
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// NewFileExporter 追加输出到文件
+func NewFileExporter(path string) (*WriterExporter, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterExporter{w: f, c: f}, nil
+}
+
+func (e *WriterExporter) Export(s SpanData) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		slog.Error("trace export err", "span", s.Name, "err", err)
+		return
+	}
+	b = append(b, '\n')
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err = e.w.Write(b); err != nil {
+		slog.Error("trace export err", "span", s.Name, "err", err)
+	}
+}
+
+// Close 关闭文件，输出到 io.Writer 时为空操作
+func (e *WriterExporter) Close() error {
+	if e.c == nil {
+		return nil
+	}
+	return e.c.Close()
+}
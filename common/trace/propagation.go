@@ -0,0 +1,167 @@
+package trace
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Inject 将上下文中的链路写入请求头
+func Inject(ctx context.Context, h http.Header) {
+	if sc, ok := parentFromContext(ctx); ok {
+		h.Set(Header, sc.Traceparent())
+	}
+}
+
+// Extract 从请求头读取远端链路，无效时返回原上下文
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceparent(h.Get(Header))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemote(ctx, sc)
+}
+
+// StartHttpClient 开始 http 客户端 span 并注入请求头
+func StartHttpClient(req *http.Request) (*http.Request, *Span) {
+	ctx, span := Start(req.Context(), req.Method+" "+req.URL.Host, KindClient)
+	if span == nil {
+		return req, nil
+	}
+	req = req.WithContext(ctx)
+	span.SetAttr("http.method", req.Method)
+	span.SetAttr("http.url", req.URL.Redacted())
+	Inject(ctx, req.Header)
+	return req, span
+}
+
+// UnaryClientInterceptor grpc 客户端拦截器，开始客户端 span 并通过 metadata 传递链路
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := Start(ctx, method, KindClient)
+		if span == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		defer span.End()
+		span.SetAttr("rpc.system", "grpc")
+		span.SetAttr("rpc.method", method)
+		span.SetAttr("net.peer", cc.Target())
+		ctx = metadata.AppendToOutgoingContext(ctx, Header, span.Context().Traceparent())
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err != nil {
+			span.SetAttr("rpc.code", status.Code(err).String())
+			span.SetError(err)
+		}
+		return err
+	}
+}
+
+// StreamClientInterceptor grpc 流式客户端拦截器，span 在流结束时结束
+// 即 RecvMsg 返回 io.EOF 或错误，客户端流的单个响应收到后
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := Start(ctx, method, KindClient)
+		if span == nil {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		span.SetAttr("rpc.system", "grpc")
+		span.SetAttr("rpc.method", method)
+		if cc != nil {
+			span.SetAttr("net.peer", cc.Target())
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, Header, span.Context().Traceparent())
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			endRpc(span, err)
+			return nil, err
+		}
+		return &clientStream{ClientStream: cs, span: span, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	span          *Span
+	serverStreams bool
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.span.End()
+	case err != nil:
+		endRpc(s.span, err)
+	case !s.serverStreams:
+		s.span.End()
+	}
+	return err
+}
+
+// UnaryServerInterceptor grpc 服务端拦截器，按 metadata 中的链路开始服务端 span
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !Enabled() {
+			return handler(ctx, req)
+		}
+		ctx, span := Start(extractMetadata(ctx), info.FullMethod, KindServer)
+		defer span.End()
+		span.SetAttr("rpc.system", "grpc")
+		resp, err := handler(ctx, req)
+		if err != nil {
+			span.SetAttr("rpc.code", status.Code(err).String())
+			span.SetError(err)
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor grpc 流式服务端拦截器，按 metadata 中的链路开始服务端 span
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !Enabled() {
+			return handler(srv, ss)
+		}
+		ctx, span := Start(extractMetadata(ss.Context()), info.FullMethod, KindServer)
+		defer span.End()
+		span.SetAttr("rpc.system", "grpc")
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		if err != nil {
+			span.SetAttr("rpc.code", status.Code(err).String())
+			span.SetError(err)
+		}
+		return err
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func extractMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if vs := md.Get(Header); len(vs) > 0 {
+		if sc, err := ParseTraceparent(vs[0]); err == nil {
+			return ContextWithRemote(ctx, sc)
+		}
+	}
+	return ctx
+}
+
+func endRpc(span *Span, err error) {
+	span.SetAttr("rpc.code", status.Code(err).String())
+	span.SetError(err)
+	span.End()
+}
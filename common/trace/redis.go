@@ -0,0 +1,53 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisHook redis 命令的子 span，仅在上下文中已有链路时生效
+// e.g. rdb.AddHook(trace.RedisHook{})
+type RedisHook struct{}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := StartChild(ctx, "redis "+cmd.Name(), KindClient)
+		if span == nil {
+			return next(ctx, cmd)
+		}
+		defer span.End()
+		span.SetAttr("db.system", "redis")
+		span.SetAttr("db.operation", cmd.Name())
+		err := next(ctx, cmd)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			span.SetError(err)
+		}
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := StartChild(ctx, "redis pipeline", KindClient)
+		if span == nil {
+			return next(ctx, cmds)
+		}
+		defer span.End()
+		span.SetAttr("db.system", "redis")
+		span.SetAttr("db.commands", len(cmds))
+		err := next(ctx, cmds)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			span.SetError(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,313 @@
+// Package trace W3C traceparent 链路追踪
+//
+// 服务端按请求头 traceparent 继续链路（没有时新建），客户端调用时注入请求头，
+// span 结束后交给 Exporter 输出，未设置 Exporter 时不产生 span
+//
+//	trace.SetExporter(trace.NewWriterExporter(os.Stdout))
+//	ctx, span := trace.Start(ctx, "load user", trace.KindInternal)
+//	defer span.End()
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	KindServer   = "server"
+	KindClient   = "client"
+	KindInternal = "internal"
+
+	// Header W3C 链路请求头
+	Header = "traceparent"
+	// GinKey 在 gin.Context 中保存 span 的键，gin.Context 不会把自定义类型的键转给 Request.Context
+	GinKey = "npx:trace_span"
+
+	flagSampled = 0x01
+)
+
+type TraceId [16]byte
+
+func (t TraceId) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceId) IsValid() bool {
+	return t != TraceId{}
+}
+
+type SpanId [8]byte
+
+func (s SpanId) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanId) IsValid() bool {
+	return s != SpanId{}
+}
+
+// SpanContext 跨进程传递的链路信息
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Flags   byte
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceId.IsValid() && sc.SpanId.IsValid()
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// Traceparent 格式化为 traceparent 请求头
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceId, sc.SpanId, sc.Flags)
+}
+
+// ParseTraceparent 解析 traceparent 请求头，格式 {version}-{trace-id}-{parent-id}-{flags}
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, errors.Errorf("invalid traceparent %q", s)
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, errors.Errorf("invalid traceparent %q", s)
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errors.Errorf("invalid traceparent %q", s)
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceId[:], []byte(parts[1])); err != nil {
+		return sc, errors.Wrapf(err, "invalid trace id %q", parts[1])
+	}
+	if _, err := hex.Decode(sc.SpanId[:], []byte(parts[2])); err != nil {
+		return sc, errors.Wrapf(err, "invalid parent id %q", parts[2])
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, errors.Wrapf(err, "invalid flags %q", parts[3])
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, errors.Errorf("invalid traceparent %q", s)
+	}
+	return sc, nil
+}
+
+// Span 一次操作，nil 时所有方法为空操作
+type Span struct {
+	mu     sync.Mutex
+	name   string
+	kind   string
+	sc     SpanContext
+	parent SpanId
+	start  time.Time
+	attrs  map[string]any
+	err    string
+	ended  bool
+}
+
+// SpanData span 结束后交给 Exporter 的数据
+type SpanData struct {
+	TraceId  string         `json:"traceId"`
+	SpanId   string         `json:"spanId"`
+	ParentId string         `json:"parentId,omitempty"`
+	Name     string         `json:"name"`
+	Kind     string         `json:"kind"`
+	Start    time.Time      `json:"start"`
+	End      time.Time      `json:"end"`
+	Duration float64        `json:"durationMs"`
+	Attrs    map[string]any `json:"attrs,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName 修改名称，如路由匹配后使用路由模板
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+func (s *Span) SetAttr(key string, val any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.attrs == nil {
+		s.attrs = make(map[string]any)
+	}
+	s.attrs[key] = val
+	s.mu.Unlock()
+}
+
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+// End 结束并导出，重复调用只导出一次
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	d := SpanData{
+		TraceId:  s.sc.TraceId.String(),
+		SpanId:   s.sc.SpanId.String(),
+		Name:     s.name,
+		Kind:     s.kind,
+		Start:    s.start,
+		End:      end,
+		Duration: float64(end.Sub(s.start).Nanoseconds()) / 1e6,
+		Attrs:    s.attrs,
+		Error:    s.err,
+	}
+	if s.parent.IsValid() {
+		d.ParentId = s.parent.String()
+	}
+	s.mu.Unlock()
+	if !s.sc.IsSampled() {
+		return
+	}
+	if e := getExporter(); e != nil {
+		e.Export(d)
+	}
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// ContextWithSpan 将 span 放入上下文
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// SpanFromContext 获取上下文中的 span，兼容 gin.Context
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	if s, ok := ctx.Value(spanKey{}).(*Span); ok {
+		return s
+	}
+	if s, ok := ctx.Value(GinKey).(*Span); ok {
+		return s
+	}
+	return nil
+}
+
+// ContextWithRemote 放入远端传来的链路信息，之后 Start 的 span 作为其子 span
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func parentFromContext(ctx context.Context) (SpanContext, bool) {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc, true
+	}
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	sc, ok := ctx.Value(remoteKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// TraceIdFromContext 当前链路id，无链路时返回空
+func TraceIdFromContext(ctx context.Context) string {
+	if sc, ok := parentFromContext(ctx); ok {
+		return sc.TraceId.String()
+	}
+	return ""
+}
+
+// Start 开始 span，上下文中有 span 或远端链路时作为子 span，否则新建链路
+// 未设置 Exporter 时返回原上下文和 nil
+func Start(ctx context.Context, name, kind string) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s := &Span{name: name, kind: kind, start: time.Now()}
+	if parent, ok := parentFromContext(ctx); ok {
+		s.sc = SpanContext{TraceId: parent.TraceId, Flags: parent.Flags}
+		s.parent = parent.SpanId
+	} else {
+		_, _ = rand.Read(s.sc.TraceId[:])
+		if sample() {
+			s.sc.Flags = flagSampled
+		}
+	}
+	_, _ = rand.Read(s.sc.SpanId[:])
+	return ContextWithSpan(ctx, s), s
+}
+
+// StartChild 仅在上下文中已有链路时开始子 span，用于 sql、缓存等，避免后台任务产生零散链路
+func StartChild(ctx context.Context, name, kind string) (context.Context, *Span) {
+	if _, ok := parentFromContext(ctx); !ok {
+		return ctx, nil
+	}
+	return Start(ctx, name, kind)
+}
+
+// sampleRate 新链路的采样率，按 math.Float64bits 存储
+var sampleRate atomic.Uint64
+
+func init() {
+	sampleRate.Store(math.Float64bits(1))
+}
+
+// SetSampleRate 设置新建链路的采样率 0~1，远端传来的链路沿用其采样标记
+func SetSampleRate(rate float64) {
+	sampleRate.Store(math.Float64bits(math.Max(0, math.Min(1, rate))))
+}
+
+func sample() bool {
+	rate := math.Float64frombits(sampleRate.Load())
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	n := uint64(0)
+	for _, v := range b {
+		n = n<<8 | uint64(v)
+	}
+	return float64(n>>11)/float64(1<<53) < rate
+}
@@ -0,0 +1,192 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type memExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *memExporter) Export(s SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.IsSampled() || sc.TraceId.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanId.String() != "00f067aa0ba902b7" {
+		t.Errorf("parsed %+v", sc)
+	}
+	if sc.Traceparent() != tp {
+		t.Errorf("format %s", sc.Traceparent())
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x",
+	} {
+		if _, err := ParseTraceparent(bad); err == nil {
+			t.Errorf("%q should be invalid", bad)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	if _, span := Start(context.Background(), "off", KindInternal); span != nil {
+		t.Fatal("span should be nil without exporter")
+	}
+	e := &memExporter{}
+	SetExporter(e)
+	defer SetExporter(nil)
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := Start(ContextWithRemote(context.Background(), remote), "root", KindServer)
+	_, child := StartChild(ctx, "child", KindClient)
+	child.SetAttr("k", "v")
+	child.End()
+	child.End()
+	root.End()
+	if _, orphan := StartChild(context.Background(), "orphan", KindClient); orphan != nil {
+		t.Error("child span needs a parent")
+	}
+
+	if len(e.spans) != 2 {
+		t.Fatalf("exported %d spans", len(e.spans))
+	}
+	c, r := e.spans[0], e.spans[1]
+	if r.TraceId != remote.TraceId.String() || r.ParentId != remote.SpanId.String() {
+		t.Errorf("root should continue remote trace %+v", r)
+	}
+	if c.TraceId != r.TraceId || c.ParentId != r.SpanId || c.Attrs["k"] != "v" {
+		t.Errorf("child %+v", c)
+	}
+	if TraceIdFromContext(ctx) != r.TraceId {
+		t.Error("trace id from context")
+	}
+
+	// 远端未采样时不导出
+	e.spans = nil
+	remote.Flags = 0
+	_, s := Start(ContextWithRemote(context.Background(), remote), "unsampled", KindServer)
+	s.End()
+	SetSampleRate(0)
+	defer SetSampleRate(1)
+	_, s = Start(context.Background(), "rate0", KindServer)
+	s.End()
+	if len(e.spans) != 0 {
+		t.Errorf("unsampled spans exported %+v", e.spans)
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	e := &memExporter{}
+	SetExporter(e)
+	defer SetExporter(nil)
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(Header)
+	}))
+	defer srv.Close()
+
+	ctx, root := Start(context.Background(), "root", KindServer)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	req, span := StartHttpClient(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	span.End()
+	root.End()
+
+	sc, err := ParseTraceparent(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceId != root.Context().TraceId || sc.SpanId != span.Context().SpanId {
+		t.Errorf("traceparent %s should carry client span", got)
+	}
+	h := http.Header{}
+	h.Set(Header, got)
+	if TraceIdFromContext(Extract(context.Background(), h)) != root.Context().TraceId.String() {
+		t.Error("extract should read traceparent")
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	SetExporter(NewWriterExporter(&buf))
+	defer SetExporter(nil)
+	_, s := Start(context.Background(), "write", KindInternal)
+	s.End()
+	var d SpanData
+	if err := json.Unmarshal([]byte(strings.TrimSpace(buf.String())), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "write" || d.SpanId != s.Context().SpanId.String() {
+		t.Errorf("exported %+v", d)
+	}
+}
+
+type eofStream struct {
+	grpc.ClientStream
+}
+
+func (eofStream) RecvMsg(any) error {
+	return io.EOF
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	e := &memExporter{}
+	SetExporter(e)
+	defer SetExporter(nil)
+
+	var got string
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		if vs := md.Get(Header); len(vs) > 0 {
+			got = vs[0]
+		}
+		return eofStream{}, nil
+	}
+	ctx, root := Start(context.Background(), "root", KindServer)
+	cs, err := StreamClientInterceptor()(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, "/svc/Watch", streamer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc, err := ParseTraceparent(got); err != nil || sc.TraceId != root.Context().TraceId {
+		t.Fatalf("traceparent %q should carry root trace", got)
+	}
+	if len(e.spans) != 0 {
+		t.Fatal("span should end with the stream")
+	}
+	if err := cs.RecvMsg(nil); err != io.EOF {
+		t.Fatal(err)
+	}
+	if len(e.spans) != 1 || e.spans[0].Name != "/svc/Watch" || e.spans[0].Error != "" {
+		t.Errorf("spans %+v", e.spans)
+	}
+}
@@ -32,20 +32,25 @@ func AesDecryptCBC(encrypted []byte, key []byte) (decrypted []byte, err error) {
 		err = errors.New(_err.Error())
 		return
 	}
-	blockSize := block.BlockSize()                              // 获取秘钥块的长度
+	blockSize := block.BlockSize() // 获取秘钥块的长度
+	if len(encrypted) == 0 || len(encrypted)%blockSize != 0 {
+		return nil, errors.New("aes: invalid encrypted length")
+	}
 	blockMode := cipher.NewCBCDecrypter(block, key[:blockSize]) // 加密模式
 	decrypted = make([]byte, len(encrypted))                    // 创建数组
 	blockMode.CryptBlocks(decrypted, encrypted)                 // 解密
-	decrypted = pkcs5UnPadding(decrypted)                       // 去除补全码
-	return decrypted, nil
+	return pkcs5UnPadding(decrypted, blockSize)                 // 去除补全码
 }
 func pkcs5Padding(ciphertext []byte, blockSize int) []byte {
 	padding := blockSize - len(ciphertext)%blockSize
 	padText := bytes.Repeat([]byte{byte(padding)}, padding)
 	return append(ciphertext, padText...)
 }
-func pkcs5UnPadding(origData []byte) []byte {
+func pkcs5UnPadding(origData []byte, blockSize int) ([]byte, error) {
 	length := len(origData)
 	unPadding := int(origData[length-1])
-	return origData[:(length - unPadding)]
+	if unPadding == 0 || unPadding > blockSize || unPadding > length {
+		return nil, errors.New("aes: invalid padding, wrong key?")
+	}
+	return origData[:(length - unPadding)], nil
 }
@@ -2,8 +2,11 @@ package https
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
+
+	"github.com/mooncake9527/npx/common/trace"
 )
 
 func New() *HTTPClient {
@@ -20,6 +23,22 @@ func NewUrl(baseUrl string) *HTTPClient {
 type HTTPClient struct {
 	BaseURL string
 	Headers map[string]string
+	ctx     context.Context
+}
+
+// WithContext 返回使用该上下文的副本，不修改原客户端，上下文中有链路时传递 traceparent
+// 副本与原客户端共用 Headers
+func (c *HTTPClient) WithContext(ctx context.Context) *HTTPClient {
+	cp := *c
+	cp.ctx = ctx
+	return &cp
+}
+
+func (c *HTTPClient) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
 }
 
 func (c *HTTPClient) SetHeaders(headers map[string]string) *HTTPClient {
@@ -48,7 +67,7 @@ func (c *HTTPClient) Get(endpoint string) ([]byte, error) {
 	} else {
 		url = c.BaseURL + endpoint
 	}
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(c.context(), "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +88,7 @@ func (c *HTTPClient) Post(endpoint string, data []byte) ([]byte, error) {
 		url = c.BaseURL + endpoint
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(c.context(), "POST", url, bytes.NewBuffer(data))
 	if err != nil {
 		return nil, err
 	}
@@ -83,13 +102,17 @@ func (c *HTTPClient) Post(endpoint string, data []byte) ([]byte, error) {
 }
 
 func do(req *http.Request) ([]byte, error) {
+	req, span := trace.StartHttpClient(req)
+	defer span.End()
 	client := &http.Client{}
 	defer client.CloseIdleConnections()
 	resp, err := client.Do(req)
 	if err != nil {
+		span.SetError(err)
 		return nil, err
 	}
 	defer resp.Body.Close()
+	span.SetAttr("http.status_code", resp.StatusCode)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
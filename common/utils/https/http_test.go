@@ -1,6 +1,7 @@
 package https
 
 import (
+	"context"
 	"fmt"
 	"testing"
 )
@@ -12,3 +13,16 @@ func TestAddHeader(t *testing.T) {
 	}
 	fmt.Println(string(res))
 }
+
+func TestWithContext(t *testing.T) {
+	c := NewUrl("http://localhost")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cc := c.WithContext(ctx)
+	if cc == c || cc.context() != ctx || cc.BaseURL != c.BaseURL {
+		t.Fatal("WithContext should return a copy with ctx")
+	}
+	if c.context().Err() != nil {
+		t.Fatal("WithContext should not change the shared client")
+	}
+}
@@ -14,20 +14,102 @@ type AppCfg struct {
 	Gen         GenCfg        `mapstructure:"gen" json:"gen" yaml:"gen"`                            //是否可生成
 	GrpcServer  GrpcServerCfg `mapstructure:"grpc-server" json:"grpc-server" yaml:"grpc-server"`    //grpc服务配置
 	AccessLimit AccessLimit   `mapstructure:"access-limit" json:"access-limit" yaml:"access-limit"` //访问限制配置
+	Metrics     MetricsCfg    `mapstructure:"metrics" json:"metrics" yaml:"metrics"`                //Prometheus指标
+	Trace       TraceCfg      `mapstructure:"trace" json:"trace" yaml:"trace"`                      //链路追踪
+	Admin       AdminCfg      `mapstructure:"admin" json:"admin" yaml:"admin"`                      //运维接口
+}
+
+// AdminCfg 运维接口（健康检查、指标、pprof 等），使用独立端口，不暴露在服务端口上
+type AdminCfg struct {
+	Enable bool   `mapstructure:"enable" json:"enable" yaml:"enable"` //开启运维接口
+	Host   string `mapstructure:"host" json:"host" yaml:"host"`       //监听地址 默认127.0.0.1
+	Port   int    `mapstructure:"port" json:"port" yaml:"port"`       //端口 默认7790
+	Token  string `mapstructure:"token" json:"token" yaml:"token"`    //访问令牌，设置后请求头需带 Authorization: Bearer {token}，健康检查除外
+}
+
+func (e *AdminCfg) GetHost() string {
+	if e.Host == "" {
+		e.Host = "127.0.0.1"
+	}
+	return e.Host
+}
+
+func (e *AdminCfg) GetPort() int {
+	if e.Port < 1 {
+		e.Port = 7790
+	}
+	return e.Port
+}
+
+type TraceCfg struct {
+	Enable     bool    `mapstructure:"enable" json:"enable" yaml:"enable"`                //开启链路追踪
+	Exporter   string  `mapstructure:"exporter" json:"exporter" yaml:"exporter"`          //输出方式 stdout、file 默认stdout
+	File       string  `mapstructure:"file" json:"file" yaml:"file"`                      //exporter 为 file 时的文件 默认 {logger.director}/trace.log
+	SampleRate float64 `mapstructure:"sample-rate" json:"sample-rate" yaml:"sample-rate"` //新链路采样率 0~1 默认1
+}
+
+func (e *TraceCfg) GetSampleRate() float64 {
+	if e.SampleRate <= 0 || e.SampleRate > 1 {
+		e.SampleRate = 1
+	}
+	return e.SampleRate
+}
+
+type MetricsCfg struct {
+	Enable bool   `mapstructure:"enable" json:"enable" yaml:"enable"` //开启指标收集
+	Path   string `mapstructure:"path" json:"path" yaml:"path"`       //拉取路径 默认 /metrics
+}
+
+func (e *MetricsCfg) GetPath() string {
+	if e.Path == "" {
+		e.Path = "/metrics"
+	}
+	return e.Path
 }
 
 type ServerCfg struct {
-	Name         string `mapstructure:"name" json:"name" yaml:"name"`                            //appname
-	RemoteEnable bool   `mapstructure:"remote-enable" json:"remote-enable" yaml:"remote-enable"` //是否开启远程配置
-	Mode         string `mapstructure:"mode" json:"mode" yaml:"mode"`                            //模式
-	Host         string `mapstructure:"host" json:"host" yaml:"host"`                            //启动host
-	Port         int    `mapstructure:"port" json:"port" yaml:"port"`                            //端口
-	ReadTimeout  int    `mapstructure:"read-timeout" json:"read-timeout" yaml:"read-timeout"`    //读超时 单位秒
-	WriteTimeout int    `mapstructure:"write-timeout" json:"write-timeout" yaml:"write-timeout"` //写超时 单位秒
-	FSType       string `mapstructure:"fs-type" json:"fs-type" yaml:"fs-type"`                   //文件系统
-	I18n         bool   `mapstructure:"i18n" json:"i18n" yaml:"i18n"`                            //是否开启多语言
-	Lang         string `mapstructure:"lang" json:"lang" yaml:"lang"`                            //默认语言
-	CloseWait    int    `mapstructure:"close-wait" json:"close-wait" yaml:"close-wait"`          //服务关闭等待 秒
+	Name            string `mapstructure:"name" json:"name" yaml:"name"`                                     //appname
+	RemoteEnable    bool   `mapstructure:"remote-enable" json:"remote-enable" yaml:"remote-enable"`          //是否开启远程配置
+	Mode            string `mapstructure:"mode" json:"mode" yaml:"mode"`                                     //模式
+	Host            string `mapstructure:"host" json:"host" yaml:"host"`                                     //启动host
+	Port            int    `mapstructure:"port" json:"port" yaml:"port"`                                     //端口
+	ReadTimeout     int    `mapstructure:"read-timeout" json:"read-timeout" yaml:"read-timeout"`             //读超时 单位秒
+	WriteTimeout    int    `mapstructure:"write-timeout" json:"write-timeout" yaml:"write-timeout"`          //写超时 单位秒
+	FSType          string `mapstructure:"fs-type" json:"fs-type" yaml:"fs-type"`                            //文件系统
+	I18n            bool   `mapstructure:"i18n" json:"i18n" yaml:"i18n"`                                     //是否开启多语言
+	Lang            string `mapstructure:"lang" json:"lang" yaml:"lang"`                                     //默认语言
+	CloseWait       int    `mapstructure:"close-wait" json:"close-wait" yaml:"close-wait"`                   //服务关闭等待 秒，就绪置为 false 并注销服务后等待负载均衡摘除流量
+	ShutdownTimeout int    `mapstructure:"shutdown-timeout" json:"shutdown-timeout" yaml:"shutdown-timeout"` //等待进行中请求完成的最长时间 秒 默认30
+	TLS             TLSCfg `mapstructure:"tls" json:"tls" yaml:"tls"`                                        //配置证书后使用 https，同时支持 http/2
+	H2C             bool   `mapstructure:"h2c" json:"h2c" yaml:"h2c"`                                        //明文 http/2，用于内网服务间调用，不能与 tls 同时使用
+}
+
+type TLSCfg struct {
+	CertFile       string `mapstructure:"cert-file" json:"cert-file" yaml:"cert-file"`                   //证书文件
+	KeyFile        string `mapstructure:"key-file" json:"key-file" yaml:"key-file"`                      //私钥文件
+	ClientCA       string `mapstructure:"client-ca" json:"client-ca" yaml:"client-ca"`                   //客户端 CA 文件，设置后开启 mTLS
+	ClientAuth     string `mapstructure:"client-auth" json:"client-auth" yaml:"client-auth"`             //客户端证书 request 请求不校验、require 必须提供不校验、verify 必须提供并按 client-ca 校验，设置 client-ca 时默认 verify
+	MinVersion     string `mapstructure:"min-version" json:"min-version" yaml:"min-version"`             //最低 TLS 版本 1.2、1.3 默认1.2
+	ReloadInterval int    `mapstructure:"reload-interval" json:"reload-interval" yaml:"reload-interval"` //检查证书文件变更的间隔 秒 默认10
+}
+
+// Enable 是否配置了证书
+func (e *TLSCfg) Enable() bool {
+	return e.CertFile != "" || e.KeyFile != ""
+}
+
+func (e *TLSCfg) GetClientAuth() string {
+	if e.ClientAuth == "" && e.ClientCA != "" {
+		return "verify"
+	}
+	return e.ClientAuth
+}
+
+func (e *TLSCfg) GetReloadInterval() int {
+	if e.ReloadInterval < 1 {
+		e.ReloadInterval = 10
+	}
+	return e.ReloadInterval
 }
 
 type GrpcServerCfg struct {
@@ -79,6 +161,13 @@ func (e *ServerCfg) GetCloseWait() int {
 	return e.CloseWait
 }
 
+func (e *ServerCfg) GetShutdownTimeout() int {
+	if e.ShutdownTimeout < 1 {
+		e.ShutdownTimeout = 30
+	}
+	return e.ShutdownTimeout
+}
+
 func (e *ServerCfg) GetReadTimeout() int {
 	if e.ReadTimeout < 1 {
 		e.ReadTimeout = 20
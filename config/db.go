@@ -8,17 +8,30 @@ import (
 )
 
 type DB struct {
-	DSN            string `mapstructure:"dsn" json:"dsn" yaml:"dsn"`                                        //连接参数
-	Disable        bool   `mapstructure:"disable" json:"disable" yaml:"disable"`                            //是否启用 默认true
-	Driver         string `mapstructure:"driver" json:"driver" yaml:"driver"`                               //数据库类型
-	Prefix         string `mapstructure:"prefix" json:"prefix" yaml:"prefix"`                               //全局表前缀，单独定义TableName则不生效
-	MaxIdleConn    int    `mapstructure:"max-idle-conn" json:"max-idle-conn" yaml:"max-idle-conn"`          // 空闲中的最大连接数
-	MaxOpenConn    int    `mapstructure:"max-open-conn" json:"max-open-conn" yaml:"max-open-conn"`          // 打开到数据库的最大连接数
-	MaxLifetime    int    `mapstructure:"max-lifetime" json:"max-lifetime" yaml:"max-lifetime"`             // 链接重置时间（分）
-	LogMode        string `mapstructure:"log-mode" json:"log-mode" yaml:"log-mode"`                         // Gorm日志级别： silent、error、warn、info
-	IgnoreNotFound bool   `mapstructure:"ignore-not-found" json:"ignore-not-found" yaml:"ignore-not-found"` //忽略无记录错误
-	SlowThreshold  int    `mapstructure:"slow-threshold" json:"slow-threshold" yaml:"slow-threshold"`       // 慢查询 毫秒 大于0有效
-	DryRun         bool   `mapstructure:"dry-run" json:"dry-run" yaml:"dry-run"`                            //
+	DSN            string    `mapstructure:"dsn" json:"dsn" yaml:"dsn"`                                        //连接参数
+	Disable        bool      `mapstructure:"disable" json:"disable" yaml:"disable"`                            //是否启用 默认true
+	Driver         string    `mapstructure:"driver" json:"driver" yaml:"driver"`                               //数据库类型
+	Prefix         string    `mapstructure:"prefix" json:"prefix" yaml:"prefix"`                               //全局表前缀，单独定义TableName则不生效
+	MaxIdleConn    int       `mapstructure:"max-idle-conn" json:"max-idle-conn" yaml:"max-idle-conn"`          // 空闲中的最大连接数
+	MaxOpenConn    int       `mapstructure:"max-open-conn" json:"max-open-conn" yaml:"max-open-conn"`          // 打开到数据库的最大连接数
+	MaxLifetime    int       `mapstructure:"max-lifetime" json:"max-lifetime" yaml:"max-lifetime"`             // 链接重置时间（分）
+	LogMode        string    `mapstructure:"log-mode" json:"log-mode" yaml:"log-mode"`                         // Gorm日志级别： silent、error、warn、info
+	IgnoreNotFound bool      `mapstructure:"ignore-not-found" json:"ignore-not-found" yaml:"ignore-not-found"` //忽略无记录错误
+	SlowThreshold  int       `mapstructure:"slow-threshold" json:"slow-threshold" yaml:"slow-threshold"`       // 慢查询 毫秒 大于0有效
+	DryRun         bool      `mapstructure:"dry-run" json:"dry-run" yaml:"dry-run"`                            //
+	Replicas       []Replica `mapstructure:"replicas" json:"replicas" yaml:"replicas"`                         //只读副本
+}
+
+type Replica struct {
+	DSN    string `mapstructure:"dsn" json:"dsn" yaml:"dsn"`          //只读库连接参数
+	Weight int    `mapstructure:"weight" json:"weight" yaml:"weight"` //权重 默认1
+}
+
+func (r *Replica) GetWeight() int {
+	if r.Weight < 1 {
+		r.Weight = 1
+	}
+	return r.Weight
 }
 
 type DBCfg struct {
@@ -33,8 +46,46 @@ type DBCfg struct {
 	SlowThreshold  int           `mapstructure:"slow-threshold" json:"slow-threshold" yaml:"slow-threshold"`       // 慢查询 毫秒 大于0有效
 	IgnoreNotFound bool          `mapstructure:"ignore-not-found" json:"ignore-not-found" yaml:"ignore-not-found"` //忽略无记录错误
 	DryRun         bool          `mapstructure:"dry-run" json:"dry-run" yaml:"dry-run"`                            //
+	Migrate        bool          `mapstructure:"migrate" json:"migrate" yaml:"migrate"`                            //启动时执行已注册的迁移
+	Seed           string        `mapstructure:"seed" json:"seed" yaml:"seed"`                                     //dev/test 模式启动时加载的数据目录，只写入空表
 	LogLinePrefix  string        `mapstructure:"log-line-prefix" json:"log-line-prefix" yaml:"log-line-prefix"`    //
+	Metrics        bool          `mapstructure:"metrics" json:"metrics" yaml:"metrics"`                            //收集sql统计
+	SlowTop        int           `mapstructure:"slow-top" json:"slow-top" yaml:"slow-top"`                         //保留最慢的sql条数 默认20
+	Replicas       []Replica     `mapstructure:"replicas" json:"replicas" yaml:"replicas"`                         //只读副本
 	DBS            map[string]DB `mapstructure:"dbs" json:"dbs" yaml:"dbs"`                                        //配置多db
+	Tenant         TenantCfg     `mapstructure:"tenant" json:"tenant" yaml:"tenant"`                               //多租户
+}
+
+type TenantCfg struct {
+	Enable       bool     `mapstructure:"enable" json:"enable" yaml:"enable"`                      //开启多租户行隔离
+	Column       string   `mapstructure:"column" json:"column" yaml:"column"`                      //租户字段 默认tenant_id
+	IgnoreTables []string `mapstructure:"ignore-tables" json:"ignore-tables" yaml:"ignore-tables"` //关联时不做租户隔离的表
+	DSN          string   `mapstructure:"dsn" json:"dsn" yaml:"dsn"`                               //租户独立库连接模板 {tenant} 租户id {key} 库标识，设置后按租户分库
+	Driver       string   `mapstructure:"driver" json:"driver" yaml:"driver"`                      //租户库类型 默认同 DBCfg.Driver
+	IdleTimeout  int      `mapstructure:"idle-timeout" json:"idle-timeout" yaml:"idle-timeout"`    //租户库空闲关闭时间（分） 默认30
+}
+
+func (c *TenantCfg) GetIdleTimeout() int {
+	if c.IdleTimeout < 1 {
+		c.IdleTimeout = 30
+	}
+	return c.IdleTimeout
+}
+
+func (c *TenantCfg) GetColumn() string {
+	if c.Column == "" {
+		c.Column = "tenant_id"
+	}
+	return c.Column
+}
+
+func (c *TenantCfg) IsIgnore(table string) bool {
+	for _, t := range c.IgnoreTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *DBCfg) GetDriver(dbname string) string {
@@ -42,6 +93,10 @@ func (c *DBCfg) GetDriver(dbname string) string {
 		return c.Driver
 	}
 	if db, ok := c.DBS[dbname]; ok {
+		if db.Driver == "" {
+			//子配置未设置时继承父配置
+			return c.Driver
+		}
 		return db.Driver
 	}
 	return ""
@@ -90,3 +145,10 @@ func (c *DBCfg) GetMaxLifetime() int {
 	}
 	return c.MaxLifetime
 }
+
+func (c *DBCfg) GetSlowTop() int {
+	if c.SlowTop < 1 {
+		c.SlowTop = 20
+	}
+	return c.SlowTop
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Defaulter 扩展配置的默认值，在解码前调用
+type Defaulter interface {
+	SetDefaults()
+}
+
+// Validator 扩展配置的校验，在解码后调用
+type Validator interface {
+	Validate() error
+}
+
+type extendKey struct {
+	t    reflect.Type
+	path string
+}
+
+var extend struct {
+	sync.RWMutex
+	raw     any
+	version uint64
+	cache   map[extendKey]any
+}
+
+// SetExtend 设置 extend 配置段，加载与热更新配置时由 core 调用，之后 Extend 重新解码
+func SetExtend(raw any) {
+	extend.Lock()
+	defer extend.Unlock()
+	extend.raw = raw
+	extend.version++
+	extend.cache = nil
+}
+
+// Extend 将 extend 配置段或其子路径解码为 T，结果按类型与路径缓存直到配置变更
+//
+//	type Auth struct {
+//		BaseUrl string        `mapstructure:"baseUrl"`
+//		Timeout time.Duration `mapstructure:"timeout"`
+//	}
+//	func (a *Auth) SetDefaults()    { a.Timeout = 3 * time.Second }
+//	func (a *Auth) Validate() error { ... }
+//
+//	auth, err := config.Extend[Auth]("auth") // extend.auth
+func Extend[T any](path ...string) (T, error) {
+	key := extendKey{t: reflect.TypeOf((*T)(nil)).Elem(), path: strings.Join(path, ".")}
+	extend.RLock()
+	cached, ok := extend.cache[key]
+	raw, version := extend.raw, extend.version
+	extend.RUnlock()
+	if ok {
+		return cached.(T), nil
+	}
+
+	var t T
+	if d, ok := any(&t).(Defaulter); ok {
+		d.SetDefaults()
+	}
+	section := raw
+	if key.path != "" {
+		m, _ := raw.(map[string]any)
+		section = lookup(m, key.path)
+	}
+	name := strings.TrimSuffix("extend."+key.path, ".")
+	if section != nil {
+		if err := Decode(section, &t); err != nil {
+			return t, errors.Wrap(err, name)
+		}
+	}
+	if v, ok := any(&t).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return t, errors.Wrap(err, name)
+		}
+	}
+
+	extend.Lock()
+	if extend.version == version {
+		if extend.cache == nil {
+			extend.cache = make(map[extendKey]any)
+		}
+		extend.cache[key] = t
+	}
+	extend.Unlock()
+	return t, nil
+}
+
+// MustExtend 同 Extend，失败时 panic，用于启动阶段
+func MustExtend[T any](path ...string) T {
+	t, err := Extend[T](path...)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type authCfg struct {
+	BaseUrl string        `mapstructure:"baseUrl"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	Retry   int           `mapstructure:"retry"`
+}
+
+func (a *authCfg) SetDefaults() {
+	a.Timeout = 3 * time.Second
+	a.Retry = 2
+}
+
+func (a *authCfg) Validate() error {
+	if a.BaseUrl == "" {
+		return errors.New("baseUrl is required")
+	}
+	return nil
+}
+
+func TestExtend(t *testing.T) {
+	defer SetExtend(nil)
+	file := writeFile(t, t.TempDir(), "config.yaml", "extend:\n  authBaseUrl: http://localhost:8000\n  auth:\n    baseUrl: http://auth\n    retry: 5\n")
+	var cfg AppCfg
+	if err := NewLoader(file, WithEnvPrefix("")).Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	SetExtend(cfg.Extends)
+
+	auth, err := Extend[authCfg]("auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.BaseUrl != "http://auth" || auth.Retry != 5 || auth.Timeout != 3*time.Second {
+		t.Errorf("auth %+v", auth)
+	}
+	top := MustExtend[struct {
+		AuthBaseUrl string `mapstructure:"authBaseUrl"`
+	}]()
+	if top.AuthBaseUrl != "http://localhost:8000" {
+		t.Errorf("top %+v", top)
+	}
+	if _, err = Extend[authCfg]("missing"); err == nil {
+		t.Error("missing section should fail validation")
+	}
+
+	// 热更新后重新解码
+	SetExtend(map[string]any{"auth": map[string]any{"baseUrl": "http://auth2", "timeout": "1s"}})
+	if auth, err = Extend[authCfg]("auth"); err != nil || auth.BaseUrl != "http://auth2" || auth.Timeout != time.Second || auth.Retry != 2 {
+		t.Errorf("reloaded %+v %v", auth, err)
+	}
+}
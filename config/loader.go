@@ -0,0 +1,614 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 环境变量覆盖配置的默认前缀 e.g. NPX_SERVER_PORT=8080 覆盖 server.port
+const EnvPrefix = "NPX_"
+
+// Loader 配置加载器
+//
+// 优先级从低到高：配置文件 < 环境配置文件 {name}.{mode}{ext} < 环境变量 < 命令行参数 --server.port=8080
+//
+// map 与 any 类型的配置项（dbcfg.dbs、extend）可按 . 继续指定键：命令行参数可新增键 --dbcfg.dbs.demo.dsn=...，
+// 环境变量只能覆盖配置中已有的键 NPX_DBCFG_DBS_DEMO_DSN=...，对应不到已有键时报错
+//
+// 值中的 ENC(...) 在合并后解密，见 Cipher
+type Loader struct {
+	file      string
+	profile   string
+	envPrefix string
+	args      []string
+	strict    bool
+	cipher    *Cipher
+
+	leaves  map[string]reflect.Type
+	flags   map[string]string
+	local   map[string]any //配置文件合并结果
+	remote  map[string]any
+	raw     map[string]any
+	files   []string
+	unknown []string
+}
+
+type LoaderOption func(l *Loader)
+
+// WithProfile 指定环境，默认取合并后的 server.mode
+func WithProfile(profile string) LoaderOption {
+	return func(l *Loader) {
+		l.profile = profile
+	}
+}
+
+// WithEnvPrefix 环境变量前缀，默认 NPX_，为空时不读取环境变量
+func WithEnvPrefix(prefix string) LoaderOption {
+	return func(l *Loader) {
+		l.envPrefix = prefix
+	}
+}
+
+// WithArgs 命令行参数，只处理与配置项同名的 --a.b=v、--a.b v 参数，--config 指定配置文件
+func WithArgs(args []string) LoaderOption {
+	return func(l *Loader) {
+		l.args = args
+	}
+}
+
+// WithStrict 存在未知配置项时返回错误
+func WithStrict(strict bool) LoaderOption {
+	return func(l *Loader) {
+		l.strict = strict
+	}
+}
+
+// WithCipher 解密 ENC(...) 配置值的密钥，默认从环境变量 NPX_CONFIG_KEY、NPX_CONFIG_KEY_FILE 读取
+func WithCipher(c *Cipher) LoaderOption {
+	return func(l *Loader) {
+		l.cipher = c
+	}
+}
+
+// NewLoader file 支持 .yaml .yml .json .toml
+func NewLoader(file string, opts ...LoaderOption) *Loader {
+	l := &Loader{file: file, envPrefix: EnvPrefix}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Load 读取并合并配置后解码到 out（结构体指针）
+func (l *Loader) Load(out any) error {
+	t := reflect.TypeOf(out)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return errors.New("config: out must be a pointer to struct")
+	}
+	l.leaves = make(map[string]reflect.Type)
+	collectLeaves("", t.Elem(), l.leaves)
+	l.flags = l.parseArgs(l.leaves)
+
+	l.local = make(map[string]any)
+	l.files = nil
+	base, err := readOptional(l.file)
+	if err != nil {
+		return err
+	}
+	if base != nil {
+		mergeMap(l.local, base)
+		l.files = append(l.files, l.file)
+	}
+
+	profile := l.profile
+	if profile == "" {
+		profile = l.flags["server.mode"]
+	}
+	if profile == "" && l.envPrefix != "" {
+		profile = os.Getenv(envName(l.envPrefix, "server.mode"))
+	}
+	if profile == "" {
+		profile, _ = lookup(l.local, "server.mode").(string)
+	}
+	if profile != "" {
+		ext := filepath.Ext(l.file)
+		file := strings.TrimSuffix(l.file, ext) + "." + profile + ext
+		overlay, err := readOptional(file)
+		if err != nil {
+			return err
+		}
+		if overlay != nil {
+			mergeMap(l.local, overlay)
+			l.files = append(l.files, file)
+		}
+	}
+	if len(l.files) == 0 {
+		return errors.Wrapf(os.ErrNotExist, "config: %s", l.file)
+	}
+	return l.build(out)
+}
+
+// Merge 在配置文件之上叠加远程配置并重新解码到 out，环境变量与命令行参数仍然优先，需在 Load 之后调用
+func (l *Loader) Merge(remote map[string]any, out any) error {
+	if l.local == nil {
+		return errors.New("config: merge before load")
+	}
+	l.remote = remote
+	return l.build(out)
+}
+
+func (l *Loader) build(out any) error {
+	l.raw = copyMap(l.local)
+	if l.remote != nil {
+		mergeMap(l.raw, copyMap(l.remote))
+	}
+	if l.envPrefix != "" {
+		for path, t := range l.leaves {
+			if v, ok := os.LookupEnv(envName(l.envPrefix, path)); ok {
+				if err := l.set(path, t.Kind(), v); err != nil {
+					return err
+				}
+			}
+		}
+		envs, err := l.dynamicEnvs()
+		if err != nil {
+			return err
+		}
+		for path, v := range envs {
+			if err := l.set(path, l.kindOf(path), v); err != nil {
+				return err
+			}
+		}
+	}
+	for path, v := range l.flags {
+		if err := l.set(path, l.kindOf(path), v); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decryptValues(l.raw, "", &l.cipher); err != nil {
+		return err
+	}
+
+	l.unknown = unknownKeys("", l.raw, reflect.TypeOf(out).Elem())
+	sort.Strings(l.unknown)
+	if l.strict && len(l.unknown) > 0 {
+		return errors.Errorf("config: unknown keys %s", strings.Join(l.unknown, ", "))
+	}
+	return Decode(l.raw, out)
+}
+
+// Files 实际读取的文件
+func (l *Loader) Files() []string {
+	return l.files
+}
+
+// Unknown 配置文件与远程配置中结构体未定义的配置项 e.g. dbcfg.dns
+func (l *Loader) Unknown() []string {
+	return l.unknown
+}
+
+// Raw 合并后的原始配置
+func (l *Loader) Raw() map[string]any {
+	return l.raw
+}
+
+// Decode 将原始配置解码到 out，字符串可转为数字、布尔与 time.Duration
+func Decode(raw any, out any) error {
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           out,
+		TagName:          "mapstructure",
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(d.Decode(raw), "config: decode")
+}
+
+// Parse 按扩展名解析配置内容
+func Parse(ext string, b []byte) (map[string]any, error) {
+	m := make(map[string]any)
+	var err error
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "yaml", "yml":
+		err = yaml.Unmarshal(b, &m)
+	case "json":
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		err = d.Decode(&m)
+	case "toml":
+		err = toml.Unmarshal(b, &m)
+	default:
+		return nil, errors.Errorf("config: unsupported type %s", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func readOptional(file string) (map[string]any, error) {
+	b, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, err := Parse(filepath.Ext(file), b)
+	return m, errors.Wrapf(err, "config: parse %s", file)
+}
+
+func (l *Loader) set(path string, kind reflect.Kind, v string) error {
+	var val any = v
+	if kind == reflect.Slice || kind == reflect.Map {
+		// 列表与对象按 yaml 解析 e.g. [a, b]、{k: v}
+		var parsed any
+		if err := yaml.Unmarshal([]byte(v), &parsed); err != nil {
+			return errors.Wrapf(err, "config: %s", path)
+		}
+		val = parsed
+	}
+	if base, ok := l.dynamicPath(path); ok && l.leaves[base].Kind() == reflect.Map {
+		// 列表形式的 map 先合并为对象，避免被新键整体覆盖
+		if m, ok := mapValue(lookup(l.raw, base)); ok {
+			setPath(l.raw, strings.Split(base, "."), m)
+		}
+	}
+	setPath(l.raw, strings.Split(path, "."), val)
+	return nil
+}
+
+// dynamicPath 返回 path 所在的 map 或 any 配置项 e.g. dbcfg.dbs.demo.dsn 返回 dbcfg.dbs
+func (l *Loader) dynamicPath(path string) (string, bool) {
+	base := path
+	for {
+		i := strings.LastIndexByte(base, '.')
+		if i < 0 {
+			return "", false
+		}
+		base = base[:i]
+		if t, ok := l.leaves[base]; ok {
+			return base, t.Kind() == reflect.Map || t.Kind() == reflect.Interface
+		}
+	}
+}
+
+// kindOf 配置项的类型，map 下的结构体按字段类型，其他按字符串处理
+func (l *Loader) kindOf(path string) reflect.Kind {
+	if t, ok := l.leaves[path]; ok {
+		return t.Kind()
+	}
+	base, ok := l.dynamicPath(path)
+	if !ok || l.leaves[base].Kind() != reflect.Map {
+		return reflect.String
+	}
+	elem := l.leaves[base].Elem()
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	_, field, _ := strings.Cut(path[len(base)+1:], ".")
+	if field == "" {
+		return elem.Kind()
+	}
+	if elem.Kind() == reflect.Struct {
+		leaves := make(map[string]reflect.Type)
+		collectLeaves("", elem, leaves)
+		if t, ok := leaves[field]; ok {
+			return t.Kind()
+		}
+	}
+	return reflect.String
+}
+
+// dynamicEnvs 匹配 map 与 any 配置项下已有键的环境变量，前缀匹配但对应不到已有键时报错
+func (l *Loader) dynamicEnvs() (map[string]string, error) {
+	res := make(map[string]string)
+	for base, t := range l.leaves {
+		if t.Kind() != reflect.Map && t.Kind() != reflect.Interface {
+			continue
+		}
+		prefix := envName(l.envPrefix, base) + "_"
+		var paths map[string]string
+		for _, env := range os.Environ() {
+			name, v, _ := strings.Cut(env, "=")
+			if !strings.HasPrefix(name, prefix) || l.isLeafEnv(name) {
+				continue
+			}
+			if paths == nil {
+				paths = l.subPaths(base)
+			}
+			path, ok := paths[name]
+			if !ok {
+				return nil, errors.Errorf("config: env %s does not match any key under %s, add the key to the config file or use --%s.<key>", name, base, base)
+			}
+			res[path] = v
+		}
+	}
+	return res, nil
+}
+
+// isLeafEnv name 是否为结构体配置项的环境变量
+func (l *Loader) isLeafEnv(name string) bool {
+	for path := range l.leaves {
+		if envName(l.envPrefix, path) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// subPaths 已有配置中 base 下的配置项，键为环境变量名
+func (l *Loader) subPaths(base string) map[string]string {
+	res := make(map[string]string)
+	m, _ := mapValue(lookup(l.raw, base))
+	t := l.leaves[base]
+	if t.Kind() == reflect.Map {
+		elem := t.Elem()
+		for elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		leaves := make(map[string]reflect.Type)
+		if elem.Kind() == reflect.Struct {
+			collectLeaves("", elem, leaves)
+		}
+		for k := range m {
+			res[envName(l.envPrefix, base+"."+k)] = base + "." + k
+			for field := range leaves {
+				res[envName(l.envPrefix, base+"."+k+"."+field)] = base + "." + k + "." + field
+			}
+		}
+		return res
+	}
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			res[envName(l.envPrefix, prefix+k)] = prefix + k
+			if sm, ok := v.(map[string]any); ok {
+				walk(prefix+k+".", sm)
+			}
+		}
+	}
+	walk(base+".", m)
+	return res
+}
+
+// mapValue 对象或列表形式的 map e.g. dbs: [{demo: {...}}]，与 mapstructure 一致合并列表中的对象
+func mapValue(v any) (map[string]any, bool) {
+	switch val := v.(type) {
+	case map[string]any:
+		return val, true
+	case []any:
+		res := make(map[string]any)
+		for _, item := range val {
+			m, ok := item.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			for k, v := range m {
+				res[k] = v
+			}
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// parseArgs 提取已知配置项及 map、any 配置项下的命令行参数，其他参数忽略
+func (l *Loader) parseArgs(leaves map[string]reflect.Type) map[string]string {
+	res := make(map[string]string)
+	for i := 0; i < len(l.args); i++ {
+		arg := l.args[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, val, hasVal := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		_, known := leaves[name]
+		if _, dynamic := l.dynamicPath(name); !known && !dynamic && name != "config" {
+			continue
+		}
+		if !hasVal {
+			if l.kindOf(name) == reflect.Bool {
+				val = "true"
+			} else if i+1 < len(l.args) {
+				i++
+				val = l.args[i]
+			}
+		}
+		if name == "config" {
+			l.file = val
+			continue
+		}
+		res[name] = val
+	}
+	return res
+}
+
+func envName(prefix, path string) string {
+	return prefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(path))
+}
+
+// collectLeaves 收集结构体的配置项路径，嵌套结构体展开，其他类型作为叶子
+func collectLeaves(prefix string, t reflect.Type, leaves map[string]reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := tagName(f)
+		if name == "" {
+			continue
+		}
+		path := prefix + name
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}) {
+			collectLeaves(path+".", ft, leaves)
+			continue
+		}
+		leaves[path] = ft
+	}
+}
+
+func tagName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name
+}
+
+// unknownKeys 对照结构体查找未定义的配置项，与 mapstructure 一致不区分大小写
+func unknownKeys(prefix string, v any, t reflect.Type) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var res []string
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]any)
+		if !ok || t == reflect.TypeOf(time.Time{}) {
+			return nil
+		}
+		for k, item := range m {
+			f, found := fieldByTag(t, k)
+			if !found {
+				res = append(res, prefix+k)
+				continue
+			}
+			res = append(res, unknownKeys(prefix+k+".", item, f.Type)...)
+		}
+	case reflect.Map:
+		switch val := v.(type) {
+		case map[string]any:
+			for k, item := range val {
+				res = append(res, unknownKeys(prefix+k+".", item, t.Elem())...)
+			}
+		case []any:
+			// mapstructure 支持列表形式的 map e.g. dbs: [{demo: {...}}]
+			for _, item := range val {
+				res = append(res, unknownKeys(prefix, item, t)...)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if list, ok := v.([]any); ok {
+			for i, item := range list {
+				res = append(res, unknownKeys(strings.TrimSuffix(prefix, ".")+"["+strconv.Itoa(i)+"].", item, t.Elem())...)
+			}
+		}
+	}
+	return res
+}
+
+func fieldByTag(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name := tagName(f); name != "" && strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// mergeMap 将 src 深度合并到 dst，对象逐项合并，其他类型直接覆盖
+func mergeMap(dst, src map[string]any) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]any); ok {
+			if dm, ok := dst[k].(map[string]any); ok {
+				mergeMap(dm, sm)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+func copyMap(m map[string]any) map[string]any {
+	return copyValue(m).(map[string]any)
+}
+
+// copyValue 深拷贝对象与列表，解密等原地修改不影响原配置
+func copyValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(val))
+		for k, item := range val {
+			res[k] = copyValue(item)
+		}
+		return res
+	case []any:
+		res := make([]any, len(val))
+		for i, item := range val {
+			res[i] = copyValue(item)
+		}
+		return res
+	}
+	return v
+}
+
+func lookup(m map[string]any, path string) any {
+	var cur any = m
+	for _, k := range strings.Split(path, ".") {
+		cm, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = cm[matchKey(cm, k)]
+	}
+	return cur
+}
+
+func setPath(m map[string]any, keys []string, v any) {
+	k := matchKey(m, keys[0])
+	if len(keys) == 1 {
+		m[k] = v
+		return
+	}
+	next, ok := m[k].(map[string]any)
+	if !ok {
+		next = make(map[string]any)
+		m[k] = next
+	}
+	setPath(next, keys[1:], v)
+}
+
+// matchKey 返回 m 中不区分大小写匹配的已有键，避免同一配置项出现两次
+func matchKey(m map[string]any, key string) string {
+	if _, ok := m[key]; ok {
+		return key
+	}
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+	return key
+}
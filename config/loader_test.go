@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoader(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "config.yaml", `
+server:
+  name: app
+  mode: dev
+  port: 7000
+dbcfg:
+  driver: mysql
+  dns: typo
+  dbs:
+    - demo:
+        dsn: demo-dsn
+cors:
+  whitelist: []
+`)
+	writeFile(t, dir, "config.dev.yaml", `
+server:
+  port: 7100
+  host: 127.0.0.1
+cache:
+  addr: localhost:6379
+`)
+	t.Setenv("NPX_SERVER_PORT", "7200")
+	t.Setenv("NPX_DBCFG_LOG_MODE", "silent")
+	t.Setenv("NPX_LOGGER_LOG_IN_CONSOLE", "true")
+
+	var cfg AppCfg
+	l := NewLoader(file, WithArgs([]string{"migrate", "--server.name=cli", "-dbcfg.dry-run", "--steps", "2"}))
+	if err := l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Files()) != 2 {
+		t.Errorf("files %v", l.Files())
+	}
+	if cfg.Server.Port != 7200 || cfg.Server.Host != "127.0.0.1" || cfg.Server.Name != "cli" {
+		t.Errorf("server %+v", cfg.Server)
+	}
+	if cfg.DBCfg.LogMode != "silent" || !cfg.DBCfg.DryRun || !cfg.Logger.LogInConsole || cfg.Cache.Addr != "localhost:6379" {
+		t.Errorf("overrides %+v %+v", cfg.DBCfg, cfg.Logger)
+	}
+	if cfg.DBCfg.DBS["demo"].DSN != "demo-dsn" {
+		t.Errorf("dbs %+v", cfg.DBCfg.DBS)
+	}
+	if !reflect.DeepEqual(l.Unknown(), []string{"dbcfg.dns"}) {
+		t.Errorf("unknown %v", l.Unknown())
+	}
+
+	if err := NewLoader(file, WithStrict(true), WithEnvPrefix("")).Load(&AppCfg{}); err == nil {
+		t.Error("strict should fail on unknown keys")
+	}
+	if err := NewLoader(filepath.Join(dir, "missing.yaml")).Load(&AppCfg{}); err == nil {
+		t.Error("missing file should fail")
+	}
+}
+
+func TestLoaderDynamicKeys(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "config.yaml", `
+dbcfg:
+  dbs:
+    - demo:
+        dsn: demo-dsn
+extend:
+  app:
+    name: a
+`)
+	t.Setenv("NPX_DBCFG_DBS_DEMO_DSN", "env-dsn")
+	t.Setenv("NPX_EXTEND_APP_NAME", "b")
+
+	var cfg AppCfg
+	l := NewLoader(file, WithStrict(true), WithArgs([]string{"--dbcfg.dbs.other.dsn=flag-dsn", "--dbcfg.dbs.other.disable", "--extend.app.port", "9"}))
+	if err := l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBCfg.DBS["demo"].DSN != "env-dsn" || cfg.DBCfg.DBS["other"].DSN != "flag-dsn" || !cfg.DBCfg.DBS["other"].Disable {
+		t.Errorf("dbs %+v", cfg.DBCfg.DBS)
+	}
+	app, _ := lookup(l.Raw(), "extend.app").(map[string]any)
+	if app["name"] != "b" || app["port"] != "9" {
+		t.Errorf("extend %+v", cfg.Extends)
+	}
+
+	t.Setenv("NPX_DBCFG_DBS_MISSING_DSN", "x")
+	if err := NewLoader(file).Load(&AppCfg{}); err == nil {
+		t.Error("env under a map without an existing key should fail")
+	}
+}
+
+func TestLoaderFormats(t *testing.T) {
+	type cfg struct {
+		Name    string        `mapstructure:"name"`
+		Timeout time.Duration `mapstructure:"timeout"`
+		Tags    []string      `mapstructure:"tags"`
+		Port    int           `mapstructure:"port"`
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.toml": "name = \"a\"\ntimeout = \"3s\"\ntags = [\"x\", \"y\"]\nport = 80\n",
+		"a.json": `{"name": "a", "timeout": "3s", "tags": ["x", "y"], "port": 80}`,
+		"a.yml":  "name: a\ntimeout: 3s\ntags: [x, y]\nport: 80\n",
+	} {
+		var c cfg
+		if err := NewLoader(writeFile(t, dir, name, content), WithEnvPrefix("")).Load(&c); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if c.Name != "a" || c.Timeout != 3*time.Second || len(c.Tags) != 2 || c.Port != 80 {
+			t.Errorf("%s: %+v", name, c)
+		}
+	}
+	// 环境变量中的列表按 yaml 解析
+	t.Setenv("T_TAGS", "[a, b, c]")
+	var c cfg
+	if err := NewLoader(filepath.Join(dir, "a.yml"), WithEnvPrefix("T_")).Load(&c); err != nil || len(c.Tags) != 3 {
+		t.Errorf("env list %+v %v", c, err)
+	}
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"regexp"
+
+	"github.com/mooncake9527/npx/common/utils/cryptos"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EnvConfigKey 解密配置的密钥，AES 密钥（16、24、32 字节）或 RSA 私钥 PEM
+	EnvConfigKey = "NPX_CONFIG_KEY"
+	// EnvConfigKeyFile 密钥文件路径，未设置 NPX_CONFIG_KEY 时读取
+	EnvConfigKeyFile = "NPX_CONFIG_KEY_FILE"
+)
+
+// encRe 配置值中的加密片段，可以是整个值也可以是值的一部分 e.g. root:ENC(...)@tcp(127.0.0.1:3306)/test
+var encRe = regexp.MustCompile(`ENC\(([A-Za-z0-9+/=]+)\)`)
+
+// Cipher 配置值加解密，密文格式为 ENC(base64)
+type Cipher struct {
+	aesKey []byte
+	priKey []byte //PKCS1 PEM
+	pubKey []byte
+}
+
+// NewCipher key 为 RSA 私钥 PEM 时可加解密，为公钥 PEM 时只能加密，其他视为 AES 密钥
+func NewCipher(key []byte) (*Cipher, error) {
+	key = bytes.TrimSpace(key)
+	if !bytes.HasPrefix(key, []byte("-----BEGIN")) {
+		switch len(key) {
+		case 16, 24, 32:
+			return &Cipher{aesKey: key}, nil
+		}
+		return nil, errors.Errorf("config: aes key length must be 16, 24 or 32, got %d", len(key))
+	}
+	if bytes.Contains(key, []byte("PUBLIC KEY")) {
+		if _, err := cryptos.ParsePubKey(key); err != nil {
+			return nil, errors.Wrap(err, "config: public key")
+		}
+		return &Cipher{pubKey: key}, nil
+	}
+	pri, err := cryptos.ParsePriKey(key)
+	if err != nil {
+		if pri, err = cryptos.ParsePriKeyPkcs8(key); err != nil {
+			return nil, errors.Wrap(err, "config: private key")
+		}
+	}
+	pub, err := cryptos.PublicKeyToPem(&pri.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{priKey: cryptos.PrivateKeyToPem(pri), pubKey: pub}, nil
+}
+
+// CipherFromEnv 从 NPX_CONFIG_KEY 或 NPX_CONFIG_KEY_FILE 读取密钥，都未设置时返回 nil
+func CipherFromEnv() (*Cipher, error) {
+	if key := os.Getenv(EnvConfigKey); key != "" {
+		return NewCipher([]byte(key))
+	}
+	if file := os.Getenv(EnvConfigKeyFile); file != "" {
+		key, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "config: key file")
+		}
+		return NewCipher(key)
+	}
+	return nil, nil
+}
+
+// Encrypt 加密为 ENC(...)，用于写入配置文件
+func (c *Cipher) Encrypt(plain string) (string, error) {
+	var b []byte
+	var err error
+	if c.aesKey != nil {
+		b, err = cryptos.AesEncryptCBC([]byte(plain), c.aesKey)
+	} else {
+		b, err = cryptos.RsaEncrypt([]byte(plain), c.pubKey)
+	}
+	if err != nil {
+		return "", err
+	}
+	return "ENC(" + base64.StdEncoding.EncodeToString(b) + ")", nil
+}
+
+// Decrypt 解密值中所有的 ENC(...) 片段，没有时原样返回
+func (c *Cipher) Decrypt(val string) (string, error) {
+	var err error
+	res := encRe.ReplaceAllStringFunc(val, func(m string) string {
+		if err != nil {
+			return m
+		}
+		var b []byte
+		if b, err = base64.StdEncoding.DecodeString(encRe.FindStringSubmatch(m)[1]); err != nil {
+			return m
+		}
+		switch {
+		case c.aesKey != nil:
+			b, err = cryptos.AesDecryptCBC(b, c.aesKey)
+		case c.priKey != nil:
+			b, err = cryptos.RsaDecrypt(b, c.priKey)
+		default:
+			err = errors.New("config: public key can only encrypt")
+		}
+		return string(b)
+	})
+	if err != nil {
+		return "", err
+	}
+	return res, nil
+}
+
+// IsEncrypted 值中是否包含 ENC(...)
+func IsEncrypted(val string) bool {
+	return encRe.MatchString(val)
+}
+
+// decryptValues 解密配置中所有字符串值的 ENC(...)，存在密文但没有密钥时报错
+func decryptValues(v any, path string, c **Cipher) (any, error) {
+	switch val := v.(type) {
+	case string:
+		if !IsEncrypted(val) {
+			return val, nil
+		}
+		if *c == nil {
+			cipher, err := CipherFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			if cipher == nil {
+				return nil, errors.Errorf("config: %s is encrypted, set %s or %s", path, EnvConfigKey, EnvConfigKeyFile)
+			}
+			*c = cipher
+		}
+		plain, err := (*c).Decrypt(val)
+		return plain, errors.Wrapf(err, "config: decrypt %s", path)
+	case map[string]any:
+		for k, item := range val {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			plain, err := decryptValues(item, p, c)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = plain
+		}
+	case []any:
+		for i, item := range val {
+			plain, err := decryptValues(item, index(path, i), c)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = plain
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mooncake9527/npx/common/utils/cryptos"
+)
+
+func TestCipher(t *testing.T) {
+	pub, pri, err := cryptos.GenerateRsaKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aes, err := NewCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsa, err := NewCipher(pri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPub, err := NewCipher(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string][2]*Cipher{"aes": {aes, aes}, "rsa": {rsaPub, rsa}} {
+		enc, err := c[0].Encrypt("s3cret")
+		if err != nil || !IsEncrypted(enc) {
+			t.Fatalf("%s encrypt %s %v", name, enc, err)
+		}
+		dsn := "root:" + enc + "@tcp(127.0.0.1:3306)/test"
+		if plain, err := c[1].Decrypt(dsn); err != nil || plain != "root:s3cret@tcp(127.0.0.1:3306)/test" {
+			t.Errorf("%s decrypt %s %v", name, plain, err)
+		}
+	}
+	if _, err = rsaPub.Decrypt("ENC(AAAA)"); err == nil {
+		t.Error("public key should not decrypt")
+	}
+	other, _ := NewCipher([]byte("fedcba9876543210"))
+	enc, _ := aes.Encrypt("s3cret")
+	if plain, err := other.Decrypt(enc); err == nil && plain == "s3cret" {
+		t.Error("wrong key should not decrypt")
+	}
+	if _, err = NewCipher([]byte("short")); err == nil {
+		t.Error("invalid aes key length")
+	}
+}
+
+func TestLoaderDecrypt(t *testing.T) {
+	c, _ := NewCipher([]byte("0123456789abcdef"))
+	pwd, _ := c.Encrypt("redis-pwd")
+	key, _ := c.Encrypt("sign-key")
+	file := writeFile(t, t.TempDir(), "config.yaml", "cache:\n  password: "+pwd+"\njwt:\n  sign-key: "+key+"\n")
+
+	t.Setenv(EnvConfigKey, "")
+	t.Setenv(EnvConfigKeyFile, "")
+	err := NewLoader(file, WithEnvPrefix("")).Load(&AppCfg{})
+	if err == nil || !strings.Contains(err.Error(), EnvConfigKey) {
+		t.Errorf("missing key err %v", err)
+	}
+
+	t.Setenv(EnvConfigKeyFile, writeFile(t, filepath.Dir(file), "key", "0123456789abcdef\n"))
+	var cfg AppCfg
+	l := NewLoader(file, WithEnvPrefix(""))
+	if err = l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Cache.Password != "redis-pwd" || cfg.JWT.SignKey != "sign-key" {
+		t.Errorf("decrypted %+v %+v", cfg.Cache, cfg.JWT)
+	}
+	// 重新合并时仍从密文解密
+	if err = l.Merge(map[string]any{"jwt": map[string]any{"issuer": "npx"}}, &cfg); err != nil || cfg.Cache.Password != "redis-pwd" {
+		t.Errorf("merge %+v %v", cfg.Cache, err)
+	}
+}
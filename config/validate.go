@@ -0,0 +1,307 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldError 单个配置项的错误，Path 为 yaml 路径 e.g. dbcfg.dbs.demo.driver
+type FieldError struct {
+	Path string
+	Msg  string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// ValidationError 汇总的配置错误
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "config invalid (%d errors):", len(e))
+	for _, fe := range e {
+		b.WriteString("\n  ")
+		b.WriteString(fe.Error())
+	}
+	return b.String()
+}
+
+var (
+	dbDrivers   = []string{"mysql", "pgsql", "sqlite", "mssql"} //与 core.DBType 一致
+	logModes    = []string{"", "silent", "error", "warn", "info"}
+	schedulings = []string{"", "random", "robin"} //与 scheduling.Algorithm 一致，空为 robin
+)
+
+type validator struct {
+	errs ValidationError
+}
+
+func (v *validator) add(path, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) oneOf(path, val string, allowed ...string) {
+	for _, a := range allowed {
+		if val == a {
+			return
+		}
+	}
+	v.add(path, "must be one of %s, got %q", strings.Join(nonEmpty(allowed), "|"), val)
+}
+
+func (v *validator) required(path, val string) {
+	if strings.TrimSpace(val) == "" {
+		v.add(path, "is required")
+	}
+}
+
+// port 0 表示使用默认端口
+func (v *validator) port(path string, port int, allowZero bool) {
+	min := 1
+	if allowZero {
+		min = 0
+	}
+	if port < min || port > 65535 {
+		v.add(path, "must be between %d and 65535, got %d", min, port)
+	}
+}
+
+func (v *validator) nonNegative(path string, n int) {
+	if n < 0 {
+		v.add(path, "must not be negative, got %d", n)
+	}
+}
+
+func (v *validator) duration(path string, d time.Duration) {
+	if d < 0 {
+		v.add(path, "must not be negative, got %s", d)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func nonEmpty(list []string) []string {
+	res := make([]string, 0, len(list))
+	for _, s := range list {
+		if s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func index(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+// Validate 校验配置，一次返回所有问题，未设置的项由 Get 方法取默认值不视为错误
+func (c *AppCfg) Validate() error {
+	v := &validator{}
+	c.Server.validate(v, "server")
+	c.GrpcServer.validate(v, "grpc-server")
+	c.Logger.validate(v, "logger")
+	c.DBCfg.validate(v, "dbcfg")
+	c.Cache.validate(v, "cache")
+	c.Cors.validate(v, "cors")
+	c.AccessLimit.validate(v, "access-limit")
+	c.Metrics.validate(v, "metrics")
+	c.Trace.validate(v, "trace")
+	if c.Server.RemoteEnable {
+		c.Remote.validate(v, "remote")
+	}
+	if c.Admin.Enable {
+		v.port("admin.port", c.Admin.Port, true)
+		if c.Admin.GetPort() == c.Server.GetPort() {
+			v.add("admin.port", "must differ from server.port %d", c.Server.GetPort())
+		}
+	}
+	return v.err()
+}
+
+func (e *ServerCfg) validate(v *validator, path string) {
+	v.oneOf(path+".mode", e.Mode, "", "dev", "test", "prod")
+	v.port(path+".port", e.Port, true)
+	v.nonNegative(path+".read-timeout", e.ReadTimeout)
+	v.nonNegative(path+".write-timeout", e.WriteTimeout)
+	v.nonNegative(path+".close-wait", e.CloseWait)
+	v.nonNegative(path+".shutdown-timeout", e.ShutdownTimeout)
+	if e.TLS.Enable() {
+		e.TLS.validate(v, path+".tls")
+		if e.H2C {
+			v.add(path+".h2c", "can not be used with tls, https already supports http/2")
+		}
+	}
+}
+
+func (e *TLSCfg) validate(v *validator, path string) {
+	v.required(path+".cert-file", e.CertFile)
+	v.required(path+".key-file", e.KeyFile)
+	v.oneOf(path+".client-auth", e.ClientAuth, "", "request", "require", "verify")
+	if e.ClientAuth == "verify" && e.ClientCA == "" {
+		v.add(path+".client-ca", "is required when client-auth is verify")
+	}
+	v.oneOf(path+".min-version", e.MinVersion, "", "1.2", "1.3")
+	v.nonNegative(path+".reload-interval", e.ReloadInterval)
+}
+
+func (e *GrpcServerCfg) validate(v *validator, path string) {
+	if e.Enable {
+		v.port(path+".port", e.Port, true)
+	}
+}
+
+func (z *LogCfg) validate(v *validator, path string) {
+	v.oneOf(path+".level", strings.ToLower(z.Level), "", "debug", "info", "warn", "error")
+	v.oneOf(path+".format", strings.ToLower(z.Format), "", "json", "text")
+	v.nonNegative(path+".max-age", z.MaxAge)
+	v.nonNegative(path+".max-size", z.MaxSize)
+	v.nonNegative(path+".max-backups", z.MaxBackups)
+}
+
+func (c *DBCfg) validate(v *validator, path string) {
+	if c.DSN != "" {
+		v.oneOf(path+".driver", c.Driver, dbDrivers...)
+	}
+	v.oneOf(path+".log-mode", strings.ToLower(c.LogMode), logModes...)
+	v.nonNegative(path+".max-idle-conn", c.MaxIdleConns)
+	v.nonNegative(path+".max-open-conn", c.MaxOpenConns)
+	v.nonNegative(path+".max-lifetime", c.MaxLifetime)
+	v.nonNegative(path+".slow-threshold", c.SlowThreshold)
+	v.nonNegative(path+".slow-top", c.SlowTop)
+	validateReplicas(v, path+".replicas", c.Replicas)
+	keys := make([]string, 0, len(c.DBS))
+	for key := range c.DBS {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		db := c.DBS[key]
+		if db.Disable {
+			continue
+		}
+		p := path + ".dbs." + key
+		v.required(p+".dsn", db.DSN)
+		driver := db.Driver
+		if driver == "" {
+			driver = c.Driver
+		}
+		v.oneOf(p+".driver", driver, dbDrivers...)
+		v.oneOf(p+".log-mode", strings.ToLower(db.LogMode), logModes...)
+		v.nonNegative(p+".max-idle-conn", db.MaxIdleConn)
+		v.nonNegative(p+".max-open-conn", db.MaxOpenConn)
+		v.nonNegative(p+".max-lifetime", db.MaxLifetime)
+		v.nonNegative(p+".slow-threshold", db.SlowThreshold)
+		validateReplicas(v, p+".replicas", db.Replicas)
+	}
+	if c.Tenant.Enable || c.Tenant.DSN != "" {
+		p := path + ".tenant"
+		if c.Tenant.DSN != "" && !strings.Contains(c.Tenant.DSN, "{tenant}") {
+			v.add(p+".dsn", "must contain {tenant}")
+		}
+		if c.Tenant.Driver != "" {
+			v.oneOf(p+".driver", c.Tenant.Driver, dbDrivers...)
+		}
+		v.nonNegative(p+".idle-timeout", c.Tenant.IdleTimeout)
+	}
+}
+
+func validateReplicas(v *validator, path string, rs []Replica) {
+	for i, r := range rs {
+		v.required(index(path, i)+".dsn", r.DSN)
+		v.nonNegative(index(path, i)+".weight", r.Weight)
+	}
+}
+
+func (c *CacheCfg) validate(v *validator, path string) {
+	v.oneOf(path+".type", c.Type, "", "memory", "redis")
+	if c.Type == "redis" {
+		v.required(path+".addr", c.Addr)
+	}
+	v.nonNegative(path+".db", c.DB)
+}
+
+func (c *CORS) validate(v *validator, path string) {
+	if !c.Enable {
+		return
+	}
+	v.oneOf(path+".mode", c.Mode, "allow-all", "whitelist", "strict-whitelist")
+	if c.Mode != "whitelist" && c.Mode != "strict-whitelist" {
+		return
+	}
+	if len(c.Whitelist) == 0 {
+		v.add(path+".whitelist", "is required in %s mode", c.Mode)
+	}
+	for i, w := range c.Whitelist {
+		v.required(index(path+".whitelist", i)+".allow-origin", w.AllowOrigin)
+	}
+}
+
+func (s *AccessLimit) validate(v *validator, path string) {
+	if s.Enable {
+		v.duration(path+".duration", s.Duration)
+		v.nonNegative(path+".total", s.Total)
+	}
+}
+
+func (e *MetricsCfg) validate(v *validator, path string) {
+	if e.Enable && e.Path != "" && !strings.HasPrefix(e.Path, "/") {
+		v.add(path+".path", "must start with /, got %q", e.Path)
+	}
+}
+
+func (e *TraceCfg) validate(v *validator, path string) {
+	if !e.Enable {
+		return
+	}
+	v.oneOf(path+".exporter", e.Exporter, "", "stdout", "file")
+	if e.SampleRate < 0 || e.SampleRate > 1 {
+		v.add(path+".sample-rate", "must be between 0 and 1, got %v", e.SampleRate)
+	}
+}
+
+func (e *RemoteCfg) validate(v *validator, path string) {
+	v.oneOf(path+".provider", e.Provider, "etcd", "consul")
+	v.required(path+".endpoint", e.Endpoint)
+	v.required(path+".path", e.Path)
+	v.oneOf(path+".config-type", e.ConfigType, "", "yaml", "yml", "json", "toml")
+}
+
+// Validate 校验服务注册与发现配置，路径相对于该配置 e.g. registers[0].port
+func (c *Config) Validate() error {
+	v := &validator{}
+	v.oneOf("driver", c.Driver, "etcd", "consul")
+	if len(c.Endpoints) == 0 {
+		v.add("endpoints", "is required")
+	}
+	v.duration("timeout", c.Timeout)
+	for i, r := range c.Registers {
+		p := index("registers", i)
+		v.required(p+".name", r.Name)
+		v.required(p+".addr", r.Addr)
+		v.port(p+".port", r.Port, false)
+		v.oneOf(p+".protocol", r.Protocol, "http", "grpc")
+		v.nonNegative(p+".weight", r.Weight)
+		v.duration(p+".interval", r.Interval)
+		v.duration(p+".timeout", r.Timeout)
+	}
+	for i, d := range c.Discoveries {
+		if !d.Enable {
+			continue
+		}
+		p := index("discoveries", i)
+		v.required(p+".name", d.Name)
+		v.oneOf(p+".scheduling-algorithm", d.SchedulingAlgorithm, schedulings...)
+		v.nonNegative(p+".fail-limit", d.FailLimit)
+		v.nonNegative(p+".retry-time", d.RetryTime)
+	}
+	return v.err()
+}
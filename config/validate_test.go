@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	var empty AppCfg
+	if err := empty.Validate(); err != nil {
+		t.Errorf("empty config should use defaults %v", err)
+	}
+
+	cfg := AppCfg{
+		Server: ServerCfg{Mode: "staging", Port: 70000},
+		DBCfg: DBCfg{
+			DSN:    "root@tcp(127.0.0.1)/test",
+			Driver: "oracle",
+			DBS: map[string]DB{
+				"demo": {Driver: "mysql"},
+				"off":  {Disable: true},
+			},
+			Replicas: []Replica{{DSN: "r1"}, {Weight: -1}},
+		},
+		Cors:  CORS{Enable: true, Mode: "whitelist"},
+		Trace: TraceCfg{Enable: true, SampleRate: 2},
+	}
+	err := cfg.Validate()
+	var ve ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("err %v", err)
+	}
+	var paths []string
+	for _, fe := range ve {
+		paths = append(paths, fe.Path)
+	}
+	want := []string{
+		"server.mode", "server.port", "dbcfg.driver",
+		"dbcfg.replicas[1].dsn", "dbcfg.replicas[1].weight", "dbcfg.dbs.demo.dsn",
+		"cors.whitelist", "trace.sample-rate",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths %v", paths)
+	}
+	if !strings.Contains(err.Error(), `dbcfg.driver: must be one of mysql|pgsql|sqlite|mssql, got "oracle"`) {
+		t.Errorf("message %s", err)
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	cfg := AppCfg{Server: ServerCfg{H2C: true, TLS: TLSCfg{CertFile: "tls.crt", ClientAuth: "verify", MinVersion: "1.1"}}}
+	var ve ValidationError
+	if !errors.As(cfg.Validate(), &ve) {
+		t.Fatal("expected validation error")
+	}
+	var paths []string
+	for _, fe := range ve {
+		paths = append(paths, fe.Path)
+	}
+	want := []string{"server.tls.key-file", "server.tls.client-ca", "server.tls.min-version", "server.h2c"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths %v", paths)
+	}
+}
+
+func TestValidateRD(t *testing.T) {
+	c := Config{
+		Driver:    "etcd",
+		Endpoints: []string{"127.0.0.1:2379"},
+		Registers: []*RegisterNode{
+			{Name: "a", Addr: "127.0.0.1", Port: 80, Protocol: "http"},
+			{Name: "b", Port: 0, Protocol: "tcp"},
+		},
+		Discoveries: []*DiscoveryNode{
+			{Enable: true, Name: "a", SchedulingAlgorithm: "weight"},
+			{Enable: false, SchedulingAlgorithm: "weight"},
+		},
+	}
+	err := c.Validate()
+	var ve ValidationError
+	if !errors.As(err, &ve) || len(ve) != 4 {
+		t.Fatalf("err %v", err)
+	}
+	if ve[0].Path != "registers[1].addr" || ve[3].Path != "discoveries[0].scheduling-algorithm" {
+		t.Errorf("errors %v", ve)
+	}
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/common/response"
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core/metrics"
+	"github.com/mooncake9527/npx/models"
+)
+
+var (
+	adminLock   sync.Mutex
+	adminEngine *gin.Engine
+	startTime   = time.Now()
+)
+
+// AdminEngine 运维接口的路由，监听 admin.port，可挂载自定义的运维接口
+//
+//	GET /health                存活
+//	GET /ready                 就绪，关闭时返回 503
+//	GET /metrics               Prometheus指标，开启 metrics 时
+//	GET /version               版本与运行时信息
+//	GET /config                运行中的配置，密码等已掩码
+//	GET /routes                服务端口上注册的路由
+//	GET /db/stats              db连接池与sql统计
+//	GET /services              服务发现的节点，只包含 rd.Component 等组件发现的服务
+//	GET /debug/pprof/*         pprof
+func AdminEngine() *gin.Engine {
+	adminLock.Lock()
+	defer adminLock.Unlock()
+	if adminEngine == nil {
+		adminEngine = newAdminEngine()
+	}
+	return adminEngine
+}
+
+func newAdminEngine() *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/health", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/ready", ReadyHandler)
+
+	g := r.Group("", adminAuth)
+	g.GET("/metrics", func(c *gin.Context) {
+		if !GetCfg().Metrics.Enable {
+			c.String(http.StatusNotFound, "metrics disabled")
+			return
+		}
+		metrics.Default.Handler().ServeHTTP(c.Writer, c.Request)
+	})
+	g.GET("/version", versionHandler)
+	g.GET("/config", configHandler)
+	g.GET("/routes", routesHandler)
+	g.GET("/db/stats", DbStatsHandler)
+	g.GET("/services", servicesHandler)
+
+	g.GET("/debug/pprof/", gin.WrapF(pprof.Index))
+	g.GET("/debug/pprof/cmdline", gin.WrapF(pprof.Cmdline))
+	g.GET("/debug/pprof/profile", gin.WrapF(pprof.Profile))
+	g.GET("/debug/pprof/symbol", gin.WrapF(pprof.Symbol))
+	g.POST("/debug/pprof/symbol", gin.WrapF(pprof.Symbol))
+	g.GET("/debug/pprof/trace", gin.WrapF(pprof.Trace))
+	g.GET("/debug/pprof/:name", func(c *gin.Context) {
+		pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Request)
+	})
+	return r
+}
+
+// adminAuth 设置了 admin.token 时校验 Authorization: Bearer {token}
+func adminAuth(c *gin.Context) {
+	token := GetCfg().Admin.Token
+	if token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	c.Next()
+}
+
+// newAdminServer 运维接口服务，未开启时返回 nil
+func newAdminServer() *http.Server {
+	if !Cfg.Admin.Enable {
+		return nil
+	}
+	return &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", Cfg.Admin.GetHost(), Cfg.Admin.GetPort()),
+		Handler:           AdminEngine(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+func versionHandler(c *gin.Context) {
+	cfg := GetCfg()
+	data := gin.H{
+		"name":       cfg.Server.Name,
+		"version":    Version,
+		"mode":       cfg.Server.Mode,
+		"goVersion":  runtime.Version(),
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+		"cpus":       runtime.NumCPU(),
+		"goroutines": runtime.NumGoroutine(),
+		"startTime":  startTime.Format(time.RFC3339),
+		"uptime":     time.Since(startTime).Round(time.Second).String(),
+		"ready":      Ready(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		data["module"] = info.Main.Path
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				data["revision"] = s.Value
+			case "vcs.time":
+				data["buildTime"] = s.Value
+			case "vcs.modified":
+				data["modified"] = s.Value == "true"
+			}
+		}
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	data["memory"] = gin.H{
+		"alloc":    mem.Alloc,
+		"sys":      mem.Sys,
+		"heapObjs": mem.HeapObjects,
+		"numGC":    mem.NumGC,
+	}
+	response.OK(c, data, "")
+}
+
+func configHandler(c *gin.Context) {
+	data, err := config.Mask(*GetCfg())
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.OK(c, data, "")
+}
+
+// Route 服务端口上注册的路由
+type Route struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+func routesHandler(c *gin.Context) {
+	routes := make([]Route, 0)
+	if r, ok := GetEngine().(*gin.Engine); ok {
+		for _, ri := range r.Routes() {
+			routes = append(routes, Route{Method: ri.Method, Path: ri.Path, Handler: ri.Handler})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	response.OK(c, routes, "")
+}
+
+// servicer 提供服务发现节点的组件 e.g. rd.Component
+type servicer interface {
+	Services() map[string][]models.ServiceNode
+}
+
+// ServiceNode 服务发现的节点
+type ServiceNode struct {
+	Id       string   `json:"id"`
+	Addr     string   `json:"addr"`
+	Port     int      `json:"port"`
+	Protocol string   `json:"protocol"`
+	Weight   int      `json:"weight"`
+	Tags     []string `json:"tags,omitempty"`
+	Enable   bool     `json:"enable"`
+}
+
+// servicesHandler 只列出已启动组件中的服务，直接使用 rd.NewRDClient 创建的客户端不在其中，没有此类组件时在 msg 中提示
+func servicesHandler(c *gin.Context) {
+	res := make(map[string][]ServiceNode)
+	found := false
+	for _, comp := range components.Started() {
+		s, ok := comp.(servicer)
+		if !ok {
+			continue
+		}
+		found = true
+		for name, nodes := range s.Services() {
+			for _, n := range nodes {
+				res[name] = append(res[name], ServiceNode{
+					Id:       n.Id,
+					Addr:     n.Addr,
+					Port:     n.Port,
+					Protocol: strings.ToLower(n.Protocol),
+					Weight:   n.Weight,
+					Tags:     n.Tags,
+					Enable:   n.Enable(),
+				})
+			}
+		}
+	}
+	msg := ""
+	if !found {
+		msg = "no discovery component started, register rd.NewComponent with core.RegisterComponent; clients from rd.NewRDClient are not listed"
+	}
+	response.OK(c, res, msg)
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/models"
+)
+
+type fakeDiscovery struct {
+	Component
+}
+
+func (fakeDiscovery) Services() map[string][]models.ServiceNode {
+	n := models.ServiceNode{RegisterNode: config.RegisterNode{Id: "a:1", Addr: "a", Port: 1, Protocol: "GRPC"}, Weight: 2}
+	n.SetEnable(true)
+	return map[string][]models.ServiceNode{"user": {n}}
+}
+
+func TestAdminEngine(t *testing.T) {
+	old := Cfg
+	defer func() {
+		Cfg = old
+		ResetState()
+	}()
+	ResetState()
+	Cfg = config.AppCfg{}
+	Cfg.Logger.Director = t.TempDir()
+	Cfg.Admin = config.AdminCfg{Enable: true, Token: "t0ken"}
+	Cfg.Cache.Password = "redis-pass"
+	Cfg.DBCfg.Driver = Sqlite.String()
+	Cfg.DBCfg.LogMode = "silent"
+	Cfg.DBCfg.DSN = "file:admin?mode=memory&cache=shared&_pwd=x"
+	Cfg.DBCfg.DBS = map[string]config.DB{"demo": {DSN: "file:admin_demo?mode=memory&cache=shared", Disable: true}}
+	Cfg.Remote.Endpoint = "user:secret@127.0.0.1:2379"
+
+	_ = RegisterComponent(fakeDiscovery{NewComponent("discovery", nil, nil)})
+	if err := Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	GetGinEngine().GET("/api/users", func(c *gin.Context) {})
+
+	get := func(path string, auth bool) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth {
+			req.Header.Set("Authorization", "Bearer t0ken")
+		}
+		w := httptest.NewRecorder()
+		AdminEngine().ServeHTTP(w, req)
+		var body map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	if code, _ := get("/health", false); code != http.StatusOK {
+		t.Fatalf("health %d", code)
+	}
+	if code, _ := get("/ready", false); code != http.StatusServiceUnavailable {
+		t.Fatalf("ready before serve %d", code)
+	}
+	if code, _ := get("/version", false); code != http.StatusUnauthorized {
+		t.Fatalf("version without token %d", code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/version", nil)
+	req.Header.Set("Authorization", "Bearer t0ke")
+	w := httptest.NewRecorder()
+	AdminEngine().ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("version with wrong token %d", w.Code)
+	}
+	code, body := get("/version", true)
+	if code != http.StatusOK || body["data"].(map[string]any)["version"] != Version {
+		t.Fatalf("version %d %v", code, body)
+	}
+
+	_, body = get("/config", true)
+	b, _ := json.Marshal(body["data"])
+	for _, secret := range []string{"redis-pass", "t0ken", "secret@"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("config leaks %s: %s", secret, b)
+		}
+	}
+	if !strings.Contains(string(b), `"endpoint":"user:******@127.0.0.1:2379"`) {
+		t.Errorf("endpoint not masked: %s", b)
+	}
+
+	_, body = get("/routes", true)
+	if b, _ := json.Marshal(body["data"]); !strings.Contains(string(b), `"path":"/api/users"`) {
+		t.Errorf("routes %s", b)
+	}
+
+	_, body = get("/services", true)
+	if b, _ := json.Marshal(body["data"]); string(b) != `{"user":[{"addr":"a","enable":true,"id":"a:1","port":1,"protocol":"grpc","weight":2}]}` {
+		t.Errorf("services %s", b)
+	}
+	if msg, _ := body["message"].(string); strings.Contains(msg, "no discovery") {
+		t.Errorf("services with discovery component should not warn %s", msg)
+	}
+
+	_, body = get("/db/stats", true)
+	if _, ok := body["data"].(map[string]any)["pools"].(map[string]any)["default"]; !ok {
+		t.Errorf("db stats %v", body)
+	}
+
+	if code, _ := get("/debug/pprof/goroutine?debug=1", true); code != http.StatusOK {
+		t.Fatalf("pprof %d", code)
+	}
+
+	_ = Stop(context.Background())
+	_, body = get("/services", true)
+	if msg, _ := body["message"].(string); !strings.Contains(msg, "rd.NewRDClient") {
+		t.Errorf("services without discovery component should explain, got %q", msg)
+	}
+}
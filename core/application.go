@@ -2,16 +2,17 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/pkg/errors"
-
 	"github.com/mooncake9527/npx/core/ebus"
 
 	"os"
@@ -26,6 +27,8 @@ import (
 	"github.com/mooncake9527/npx/core/cache"
 	"github.com/mooncake9527/npx/core/locker"
 	"github.com/natefinch/lumberjack"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"gorm.io/gorm"
 )
 
@@ -37,8 +40,9 @@ var (
 	engine    http.Handler
 	dbs       = make(map[string]*gorm.DB, 0)
 	RedisLock *locker.Redis
-	Started   = make(chan byte, 1)
-	ToClose   = make(chan byte, 1)
+	Locker    locker.Locker        //有 Redis 时为 RedisLock，否则为进程内锁
+	Started   = make(chan byte, 1) //服务开始监听时写入，缓冲为 1，未被读取时后续的信号丢弃而不阻塞服务
+	ToClose   = make(chan byte, 1) //开始关闭时写入，同 Started
 )
 
 func GetEngine() http.Handler {
@@ -50,36 +54,59 @@ func SetEngine(aEngine http.Handler) {
 }
 
 func GetGinEngine() *gin.Engine {
+	r, err := ginEngine()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return r
+}
+
+func ginEngine() (*gin.Engine, error) {
 	if Cfg.Server.Mode == ModeProd.String() {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	var r *gin.Engine
-	lock.RLock()
-	defer lock.RUnlock()
+	lock.Lock()
+	defer lock.Unlock()
 	if engine == nil {
 		engine = gin.New()
 	}
-	switch engine.(type) {
-	case *gin.Engine:
-		r = engine.(*gin.Engine)
-	default:
-		log.Fatal("not support other engine")
+	r, ok := engine.(*gin.Engine)
+	if !ok {
+		return nil, errors.New("not support other engine")
 	}
-	return r
+	return r, nil
 }
 
+// Init 启动所有组件，失败时输出错误并退出进程，需要处理错误请使用 Start
 func Init() {
-	logWrite := logInit()
-	Cache = cache.New(Cfg.Cache)
-	if Cache.Type() == "redis" {
-		r := Cache.(*cache.RedisCache)
-		RedisLock = locker.NewRedis(r.GetClient())
-	}
-	dbInit(logWrite)
-	ebus.EventBus.Publish(ebus.EventCoreInit)
+	if err := Start(context.Background()); err != nil {
+		slog.Error("core init err", "err", err)
+		fmt.Println(text.Red("core init: " + err.Error()))
+		os.Exit(1)
+	}
 }
 
+// Run 启动 http 服务直到收到 SIGINT 或 SIGTERM，失败时退出进程，需要处理错误请使用 Serve
 func Run() {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	go func() {
+		if sig, ok := <-quit; ok {
+			cancel(fmt.Errorf("signal: %s", sig))
+		}
+	}()
+	if err := Serve(ctx); err != nil {
+		slog.Error("server exit", "err", err)
+		fmt.Println(text.Red("server exit: " + err.Error()))
+		os.Exit(1)
+	}
+}
+
+// Serve 启动 http 服务直到 ctx 结束后优雅关闭，监听失败时返回错误，关闭原因为 context.Cause(ctx)
+func Serve(ctx context.Context) error {
 	addr := fmt.Sprintf("%s:%d", Cfg.Server.GetHost(), Cfg.Server.GetPort())
 
 	//服务启动参数
@@ -90,65 +117,112 @@ func Run() {
 		WriteTimeout:   time.Duration(Cfg.Server.GetWriteTimeout()) * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
+	scheme := "http"
+	if Cfg.Server.TLS.Enable() {
+		r, err := newCertReloader(Cfg.Server.TLS)
+		if err != nil {
+			return errors.Join(err, Stop(context.Background()))
+		}
+		srv.TLSConfig = r.TLSConfig()
+		scheme = "https"
+	} else if Cfg.Server.H2C {
+		srv.Handler = h2c.NewHandler(srv.Handler, &http2.Server{})
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Join(fmt.Errorf("listen: %w", err), Stop(context.Background()))
+	}
+	admin := newAdminServer()
+	if admin != nil {
+		adminLn, err := net.Listen("tcp", admin.Addr)
+		if err != nil {
+			_ = ln.Close()
+			return errors.Join(fmt.Errorf("admin listen: %w", err), Stop(context.Background()))
+		}
+		go func() {
+			if err := admin.Serve(adminLn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("admin serve err", "err", err)
+			}
+		}()
+		fmt.Println(text.Green("admin started ,listen on: ") + text.Red("[ http://"+adminLn.Addr().String()+" ]"))
+	}
 	//
 	//fmt.Println(LOGO)
 
 	// 启动服务
+	serveErr := make(chan error, 1)
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatal("listen: ", err)
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
 		}
 	}()
 
 	//fmt.Println(text.Green(`orange github:`) + text.Blue(`https://github.com/mooncake/orange`))
-	fmt.Println(text.Green("server started ,listen on: ") + text.Red("[ "+addr+" ]"))
+	fmt.Println(text.Green("server started ,listen on: ") + text.Red("[ "+scheme+"://"+ln.Addr().String()+" ]"))
 
 	if Cfg.Server.Mode != ModeProd.String() {
-		fmt.Println(text.Blue(fmt.Sprintf("swagger: http://localhost:%d/swagger/index.html", Cfg.Server.Port)))
+		fmt.Println(text.Blue(fmt.Sprintf("swagger: %s://localhost:%d/swagger/index.html", scheme, Cfg.Server.Port)))
 		ip := ips.GetLocalHost()
 		if ip != "" {
-			fmt.Println(text.Blue(fmt.Sprintf("swagger: https://%s:%d/swagger/index.html", ip, Cfg.Server.Port)))
+			fmt.Println(text.Blue(fmt.Sprintf("swagger: %s://%s:%d/swagger/index.html", scheme, ip, Cfg.Server.Port)))
 		}
 	}
+	SetReady(true)
 	ebus.EventBus.Publish(ebus.EventApplicationStarted)
-	Started <- 1
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
-	ebus.EventBus.Publish(ebus.EventApplicationQuit)
+	publish(ebus.AppStarted{Addr: ln.Addr().String(), Scheme: scheme})
+	notify(Started)
+	quit := ebus.AppQuit{}
+	select {
+	case <-ctx.Done():
+		quit.Reason = context.Cause(ctx).Error()
+	case err = <-serveErr:
+		err = fmt.Errorf("serve: %w", err)
+		quit.Reason, quit.Err = err.Error(), err
+	}
+	return errors.Join(err, shutdown(srv, admin, quit))
+}
 
-	ToClose <- 1
+// notify 通知等待方，信号先写入缓冲，之后才开始等待的接收方同样能收到
+// 缓冲中已有未读取的信号时（多次启动且无人接收）丢弃本次信号，不阻塞启动与关闭
+func notify(ch chan byte) {
+	select {
+	case ch <- 1:
+	default:
+	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	slog.Info("server shutdown ...", "time", time.Now())
+// logLevel 日志级别，配置变更时动态调整
+var logLevel = new(slog.LevelVar)
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("server Shutdown:", err)
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "error":
+		return slog.LevelError
+	case "warn":
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
 	}
-
-	slog.Info("server exiting")
-	time.Sleep(time.Second * time.Duration(Cfg.Server.GetCloseWait()))
 }
 
 func logInit() io.Writer {
+	logLevel.Set(parseLogLevel(Cfg.Logger.Level))
 	opts := slog.HandlerOptions{
-		Level: slog.LevelDebug,
+		Level: logLevel,
 	}
 	var logWriter io.Writer
 	logWriter = defaultLumberjack()
 	if Cfg.Logger.LogInConsole {
 		logWriter = io.MultiWriter(logWriter, os.Stdout)
 	}
-	level := strings.ToLower(Cfg.Logger.Level)
-	switch level {
-	case "error":
-		opts.Level = slog.LevelError
-	case "warn":
-		opts.Level = slog.LevelWarn
-	default:
-		opts.Level = slog.LevelInfo
-	}
 	if strings.ToLower(Cfg.Logger.Format) == "json" {
 		iLog = slog.New(slog.NewJSONHandler(logWriter, &opts))
 	} else {
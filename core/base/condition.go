@@ -1,6 +1,9 @@
 package base
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 type Condition interface {
 	SetWhere(k string, v []interface{})
@@ -18,11 +21,13 @@ type GormPublic struct {
 	Where map[string][]interface{}
 	Order []string
 	Or    map[string][]interface{}
+	Err   error
 }
 
 type GormJoin struct {
 	Type   string
 	JoinOn string
+	Table  string //关联表
 	GormPublic
 }
 
@@ -51,6 +56,13 @@ func (e *GormPublic) SetOrder(k string) {
 	e.Order = append(e.Order, k)
 }
 
+// SetErr 记录解析错误，只保留第一个
+func (e *GormPublic) SetErr(err error) {
+	if e.Err == nil {
+		e.Err = err
+	}
+}
+
 func (e *GormCondition) SetJoinOn(t, on string) Condition {
 	if e.Join == nil {
 		e.Join = make([]*GormJoin, 0)
@@ -64,12 +76,132 @@ func (e *GormCondition) SetJoinOn(t, on string) Condition {
 	return join
 }
 
+type errSetter interface {
+	SetErr(err error)
+}
+
+// setErr 条件支持时记录错误，否则打印
+func setErr(condition Condition, err error) {
+	if es, ok := condition.(errSetter); ok {
+		es.SetErr(err)
+		return
+	}
+	fmt.Printf("SeachQuery err %v\n", err)
+}
+
+const (
+	LogicAnd = "and"
+	LogicOr  = "or"
+)
+
+// GormGroup 条件分组，组内条件按 Logic 组合后整体加括号
+// group 以 "." 分隔表示嵌套，如 group:kw.sub 为 kw 组内的子组
+type GormGroup struct {
+	parent Condition
+	Logic  string
+	items  []groupItem
+	groups map[string]*GormGroup
+}
+
+type groupItem struct {
+	sql   string
+	args  []interface{}
+	group *GormGroup
+}
+
+func newGormGroup(parent Condition) *GormGroup {
+	return &GormGroup{
+		parent: parent,
+		groups: make(map[string]*GormGroup),
+	}
+}
+
+func (e *GormGroup) SetWhere(k string, v []interface{}) {
+	e.items = append(e.items, groupItem{sql: k, args: v})
+}
+
+func (e *GormGroup) SetOr(k string, v []interface{}) {
+	e.items = append(e.items, groupItem{sql: k, args: v})
+}
+
+// SetOrder 排序与分组无关，交给上级条件
+func (e *GormGroup) SetOrder(k string) {
+	e.parent.SetOrder(k)
+}
+
+// SetJoinOn 关联与分组无关，交给上级条件
+func (e *GormGroup) SetJoinOn(t, on string) Condition {
+	return e.parent.SetJoinOn(t, on)
+}
+
+func (e *GormGroup) SetErr(err error) {
+	setErr(e.parent, err)
+}
+
+// child 获取（不存在则创建）子分组，按首次出现的顺序参与组合
+func (e *GormGroup) child(name string) *GormGroup {
+	if g, ok := e.groups[name]; ok {
+		return g
+	}
+	g := newGormGroup(e.parent)
+	e.groups[name] = g
+	e.items = append(e.items, groupItem{group: g})
+	return g
+}
+
+// path 按 "." 逐级获取子分组
+func (e *GormGroup) path(group string) *GormGroup {
+	g := e
+	for _, name := range strings.Split(group, ".") {
+		g = g.child(name)
+	}
+	return g
+}
+
+func (e *GormGroup) setLogic(logic string) {
+	if e.Logic == "" && (logic == LogicAnd || logic == LogicOr) {
+		e.Logic = logic
+	}
+}
+
+// Build 组合组内条件，无条件时返回空字符串
+func (e *GormGroup) Build() (string, []interface{}) {
+	sep := " AND "
+	if e.Logic == LogicOr {
+		sep = " OR "
+	}
+	parts := make([]string, 0, len(e.items))
+	args := make([]interface{}, 0)
+	for _, item := range e.items {
+		if item.group != nil {
+			sql, vs := item.group.Build()
+			if sql == "" {
+				continue
+			}
+			parts = append(parts, sql)
+			args = append(args, vs...)
+			continue
+		}
+		parts = append(parts, item.sql)
+		args = append(args, item.args...)
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+	return "(" + strings.Join(parts, sep) + ")", args
+}
+
 type resolveSearchTag struct {
-	Type   string
-	Column string
-	Table  string
-	On     []string
-	Join   string
+	Type    string
+	Column  string
+	Table   string
+	On      []string
+	Join    string
+	Group   string
+	Logic   string
+	Path    string
+	Fields  string
+	Default string
 }
 
 // makeTag 解析search的tag标签
@@ -103,6 +235,26 @@ func makeTag(tag string) *resolveSearchTag {
 			if len(ts) > 1 {
 				r.Join = ts[1]
 			}
+		case "group":
+			if len(ts) > 1 {
+				r.Group = ts[1]
+			}
+		case "path":
+			if len(ts) > 1 {
+				r.Path = ts[1]
+			}
+		case "fields":
+			if len(ts) > 1 {
+				r.Fields = ts[1]
+			}
+		case "default":
+			if len(ts) > 1 {
+				r.Default = ts[1]
+			}
+		case "logic":
+			if len(ts) > 1 {
+				r.Logic = strings.ToLower(ts[1])
+			}
 		}
 	}
 	return r
@@ -0,0 +1,254 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Dialect 各数据库的标识符引用与运算符差异
+type Dialect interface {
+	// Name 数据库标识，与 DBCfg.Driver 一致
+	Name() string
+	// Quote 引用标识符，带 "." 时逐段引用 e.g. schema.table
+	Quote(name string) string
+	// Column 引用 表.列
+	Column(table, column string) string
+	// ILike 不区分大小写的 like，包含一个占位符
+	ILike(column string) string
+	// Json JSON 包含条件
+	Json(column, path string, v reflect.Value) (string, []interface{}, bool)
+	// Match 全文检索，包含一个占位符
+	Match(column string) string
+}
+
+// GetDialect 根据 DBCfg.GetDriver 的返回值获取方言，未知类型按 mysql 处理
+func GetDialect(driver string) Dialect {
+	switch driver {
+	case Postgres:
+		return pgDialect{}
+	case Sqlite:
+		return sqliteDialect{}
+	case Mssql:
+		return mssqlDialect{}
+	default:
+		return mysqlDialect{}
+	}
+}
+
+// driverOf 会话所连数据库对应的驱动名，未连接时为空
+func driverOf(db *gorm.DB) string {
+	if db.Dialector == nil {
+		return ""
+	}
+	switch name := db.Dialector.Name(); name {
+	case "postgres":
+		return Postgres
+	case "sqlserver":
+		return Mssql
+	default:
+		return name
+	}
+}
+
+func quote(name, left, right string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = left + strings.ReplaceAll(p, right, right+right) + right
+	}
+	return strings.Join(parts, ".")
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return Mysql
+}
+
+func (mysqlDialect) Quote(name string) string {
+	return quote(name, "`", "`")
+}
+
+func (d mysqlDialect) Column(table, column string) string {
+	return d.Quote(table) + "." + d.Quote(column)
+}
+
+// ILike 两边转小写，不依赖列的排序规则
+func (mysqlDialect) ILike(column string) string {
+	return fmt.Sprintf("LOWER(%s) like LOWER(?)", column)
+}
+
+func (mysqlDialect) Json(column, path string, v reflect.Value) (string, []interface{}, bool) {
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		fmt.Printf("SeachQuery json marshal err %v\n", err)
+		return "", nil, false
+	}
+	return fmt.Sprintf("JSON_CONTAINS(%s, ?, '%s')", column, path), []interface{}{string(b)}, true
+}
+
+func (mysqlDialect) Match(column string) string {
+	return fmt.Sprintf("MATCH (%s) AGAINST (? IN NATURAL LANGUAGE MODE)", column)
+}
+
+type pgDialect struct{}
+
+func (pgDialect) Name() string {
+	return Postgres
+}
+
+// Quote 只引用保留字和含特殊字符的标识符，普通标识符不引用，与未引用时一样按小写匹配
+// e.g. ExampleQuery -> ExampleQuery，user -> "user"
+func (pgDialect) Quote(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		if pgPlainIdent.MatchString(p) && !pgReserved[strings.ToLower(p)] {
+			continue
+		}
+		parts[i] = quote(p, `"`, `"`)
+	}
+	return strings.Join(parts, ".")
+}
+
+var pgPlainIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// pgReserved Postgres 保留字，作为标识符时必须引用
+var pgReserved = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(`all analyse analyze and any array as asc asymmetric authorization binary both case cast
+		check collate collation column concurrently constraint create cross current_catalog current_date current_role
+		current_schema current_time current_timestamp current_user default deferrable desc distinct do else end except
+		false fetch for foreign freeze from full grant group having ilike in initially inner intersect into is isnull
+		join lateral leading left like limit localtime localtimestamp natural not notnull null offset on only or order
+		outer overlaps placing primary references returning right select session_user similar some symmetric
+		system_user table tablesample then to trailing true union unique user using variadic verbose when where window with`) {
+		m[w] = true
+	}
+	return m
+}()
+
+func (d pgDialect) Column(table, column string) string {
+	return d.Quote(table) + "." + d.Quote(column)
+}
+
+func (pgDialect) ILike(column string) string {
+	return fmt.Sprintf("%s ilike ?", column)
+}
+
+func (pgDialect) Json(column, path string, v reflect.Value) (string, []interface{}, bool) {
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		fmt.Printf("SeachQuery json marshal err %v\n", err)
+		return "", nil, false
+	}
+	if path == "$" {
+		return fmt.Sprintf("%s::jsonb @> ?::jsonb", column), []interface{}{string(b)}, true
+	}
+	return fmt.Sprintf("(%s::jsonb #> '%s') @> ?::jsonb", column, pgJsonPath(path)), []interface{}{string(b)}, true
+}
+
+func (pgDialect) Match(column string) string {
+	return fmt.Sprintf("to_tsvector(%s) @@ plainto_tsquery(?)", column)
+}
+
+// pgJsonPath 将 $.a.b[0] 转换为 Postgres 路径 {a,b,0}
+func pgJsonPath(path string) string {
+	path = strings.TrimPrefix(path, "$")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	return "{" + strings.Join(strings.Split(strings.TrimPrefix(path, "."), "."), ",") + "}"
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return Sqlite
+}
+
+func (sqliteDialect) Quote(name string) string {
+	return quote(name, "`", "`")
+}
+
+func (d sqliteDialect) Column(table, column string) string {
+	return d.Quote(table) + "." + d.Quote(column)
+}
+
+// ILike sqlite 的 like 本身对 ASCII 不区分大小写
+func (sqliteDialect) ILike(column string) string {
+	return fmt.Sprintf("%s like ?", column)
+}
+
+func (sqliteDialect) Json(column, path string, v reflect.Value) (string, []interface{}, bool) {
+	return jsonEach(fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s, '%s') WHERE json_each.value = ?)", column, path), v)
+}
+
+// Match 需为 FTS 虚拟表
+func (sqliteDialect) Match(column string) string {
+	return fmt.Sprintf("%s MATCH ?", column)
+}
+
+type mssqlDialect struct{}
+
+func (mssqlDialect) Name() string {
+	return Mssql
+}
+
+func (mssqlDialect) Quote(name string) string {
+	return quote(name, "[", "]")
+}
+
+func (d mssqlDialect) Column(table, column string) string {
+	return d.Quote(table) + "." + d.Quote(column)
+}
+
+// ILike 两边转小写，不依赖列的排序规则
+func (mssqlDialect) ILike(column string) string {
+	return fmt.Sprintf("LOWER(%s) like LOWER(?)", column)
+}
+
+func (mssqlDialect) Json(column, path string, v reflect.Value) (string, []interface{}, bool) {
+	return jsonEach(fmt.Sprintf("EXISTS (SELECT 1 FROM OPENJSON(%s, '%s') WHERE [value] = ?)", column, path), v)
+}
+
+func (mssqlDialect) Match(column string) string {
+	return fmt.Sprintf("FREETEXT(%s, ?)", column)
+}
+
+// jsonEach 逐个判断路径下的值（或数组元素）等于 v，v 为切片时需全部包含
+func jsonEach(each string, v reflect.Value) (string, []interface{}, bool) {
+	values := []interface{}{v.Interface()}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		values = make([]interface{}, 0, v.Len())
+		for j := 0; j < v.Len(); j++ {
+			values = append(values, v.Index(j).Interface())
+		}
+	}
+	if len(values) == 0 {
+		return "", nil, false
+	}
+	if len(values) == 1 {
+		return each, values, true
+	}
+	parts := make([]string, len(values))
+	for j := range values {
+		parts[j] = each
+	}
+	return "(" + strings.Join(parts, " AND ") + ")", values, true
+}
+
+var jsonPathRe = regexp.MustCompile(`^\$(\.[A-Za-z0-9_]+|\[[0-9]+\])*$`)
+
+// jsonSql JSON 包含条件，path 默认 $
+func jsonSql(d Dialect, column, path string, v reflect.Value) (string, []interface{}, bool) {
+	if path == "" {
+		path = "$"
+	}
+	if !jsonPathRe.MatchString(path) {
+		fmt.Printf("SeachQuery json path %s invalid\n", path)
+		return "", nil, false
+	}
+	return d.Json(column, path, v)
+}
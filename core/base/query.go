@@ -19,19 +19,33 @@ import (
 *	lt / lte 小于 / 小于等于
 *	left  / ileft ：like xxx%
 *	right / iright  : like %xxx
-*	in
+*	in / notin  notin 字段须为切片/数组，否则返回错误
+*	ne  不等于
+*	between  区间，字段为两个元素的切片/数组，单边为零值时只比较另一边
+*	json  JSON 包含，可配合 path 指定 JSON 路径 e.g. type:json;path:$.tags
+*	match  全文检索
 *	isnull
 *  	order 排序		e.g. order[key]=desc     order[key]=asc
+*  	sort 白名单排序	e.g. sort=-createdAt,name  字段需在 fields 中声明，default 为未传时的默认排序
+*  		Sort string `json:"sort" form:"sort" query:"type:sort;fields:createdAt=created_at,name;default:-createdAt"`
 *   "-" 忽略该字段
 *  @Param table
 *  	table 不填默认取 TableName值
 *  @Param column
 *  	column 不填以结构体字段
+*  @Param group
+*  	group 同组条件整体加括号，"." 分隔表示嵌套分组 e.g. group:kw  group:kw.sub
+*  @Param logic
+*  	logic 组内条件的组合方式 and / or，默认 and
+*  数据库方言由 DBCfg.GetDriver 决定：mysql/sqlite 使用反引号，pgsql 使用双引号，mssql 使用方括号
 *  eg：
 *  type ExampleQuery struct{
 *  	Name     string `json:"name" query:"type:like;column:name;table:exampale"`
 * 		Status   int    `json:"status" query:"type:gt"`
+*  	Title    string `json:"title" query:"type:like;group:kw;logic:or"`
+*  	Content  string `json:"content" query:"type:like;group:kw"`
 *  }
+*  => name like ? AND status > ? AND (title like ? OR content like ?)
 *  func (ExampleQuery) TableName() string {
 *		return "ExampleQuery"
 *  }
@@ -47,6 +61,10 @@ const (
 	Mysql = "mysql"
 	// Postgres 数据库标识
 	Postgres = "pgsql"
+	// Sqlite 数据库标识
+	Sqlite = "sqlite"
+	// Mssql 数据库标识
+	Mssql = "mssql"
 )
 
 // ResolveSearchQuery 解析
@@ -57,11 +75,27 @@ const (
  *	lt / lte 小于 / 小于等于
  *	left  / ileft ：like xxx%
  *	right / iright  : like %xxx
- *	in
+ *	in / notin
+ *	ne  不等于
+ *	between  区间
+ *	json  JSON 包含
+ *	match  全文检索
  *	isnull
  *  order 排序		e.g. order[key]=desc     order[key]=asc
+ *  sort 白名单排序	e.g. sort=-createdAt,name
+ *  group / logic 分组条件，组内按 logic 组合后加括号
  */
 func ResolveSearchQuery(driver string, q any, condition Condition, pTName string) {
+	groups := newGormGroup(condition)
+	resolveSearchQuery(driver, q, condition, pTName, groups)
+	for _, item := range groups.items {
+		if sql, args := item.group.Build(); sql != "" {
+			condition.SetWhere(sql, args)
+		}
+	}
+}
+
+func resolveSearchQuery(driver string, q any, condition Condition, pTName string, groups *GormGroup) {
 	qType := reflect.TypeOf(q)
 	qValue := reflect.ValueOf(q)
 	var tag string
@@ -90,17 +124,18 @@ func ResolveSearchQuery(driver string, q any, condition Condition, pTName string
 		tag, ok = qType.Field(i).Tag.Lookup(FromQueryTag)
 		if !ok {
 			//递归调用
-			ResolveSearchQuery(driver, qValue.Field(i).Interface(), condition, tname)
+			resolveSearchQuery(driver, qValue.Field(i).Interface(), condition, tname, groups)
 			continue
 		}
 		switch tag {
 		case "-":
 			continue
 		}
-		if qValue.Field(i).IsZero() {
+		t = makeTag(tag)
+		//sort 未传时使用默认排序
+		if qValue.Field(i).IsZero() && QueryTag(t.Type) != SORT {
 			continue
 		}
-		t = makeTag(tag)
 		if t.Column == "" {
 			t.Column = utils.SnakeCase(qType.Field(i).Name, false)
 		}
@@ -108,12 +143,15 @@ func ResolveSearchQuery(driver string, q any, condition Condition, pTName string
 			t.Table = tname
 		}
 
-		//解析 Postgres `语法不支持，单独适配
-		if driver == Postgres {
-			pgSql(driver, t, condition, qValue, i, tname)
-		} else {
-			otherSql(driver, t, condition, qValue, i, tname)
+		//分组条件先收集到组内，解析完成后整体加括号
+		cur := condition
+		if t.Group != "" {
+			g := groups.path(t.Group)
+			g.setLogic(t.Logic)
+			cur = g
 		}
+
+		dialectSql(driver, t, cur, qValue, i, tname)
 	}
 }
 
@@ -132,164 +170,156 @@ const (
 	LT        QueryTag = "lt"
 	LTE       QueryTag = "lte"
 	IN        QueryTag = "in"
+	NOTIN     QueryTag = "notin"
+	NE        QueryTag = "ne"
+	BETWEEN   QueryTag = "between"
+	JSON      QueryTag = "json"
+	MATCH     QueryTag = "match"
 	ISNULL    QueryTag = "isnull"
 	ISNOTNULL QueryTag = "isnotnull"
 	ORDER     QueryTag = "order"
+	SORT      QueryTag = "sort"
 	JOIN      QueryTag = "join"
 )
 
-func pgSql(driver string, t *resolveSearchTag, condition Condition, qValue reflect.Value, i int, tname string) {
+// dialectSql 按数据库方言生成条件
+func dialectSql(driver string, t *resolveSearchTag, condition Condition, qValue reflect.Value, i int, tname string) {
+	d := GetDialect(driver)
+	column := d.Column(t.Table, t.Column)
 	if t.Type == "" {
-		condition.SetWhere(fmt.Sprintf("%s.%s = ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+		condition.SetWhere(fmt.Sprintf("%s = ?", column), []interface{}{qValue.Field(i).Interface()})
 		return
 	}
 	qtag := QueryTag(t.Type)
 	switch qtag {
 	case EQ:
-		condition.SetWhere(fmt.Sprintf("%s.%s = ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
-		return
-	case ILIKE:
-		condition.SetWhere(fmt.Sprintf("%s.%s ilike ?", t.Table, t.Column), []interface{}{"%" + qValue.Field(i).String() + "%"})
+		condition.SetWhere(fmt.Sprintf("%s = ?", column), []interface{}{qValue.Field(i).Interface()})
 		return
-	case LIKE:
-		condition.SetWhere(fmt.Sprintf("%s.%s like ?", t.Table, t.Column), []interface{}{"%" + qValue.Field(i).String() + "%"})
+	case NE:
+		condition.SetWhere(fmt.Sprintf("%s <> ?", column), []interface{}{qValue.Field(i).Interface()})
 		return
 	case GT:
-		condition.SetWhere(fmt.Sprintf("%s.%s > ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+		condition.SetWhere(fmt.Sprintf("%s > ?", column), []interface{}{qValue.Field(i).Interface()})
 		return
 	case GTE:
-		condition.SetWhere(fmt.Sprintf("%s.%s >= ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+		condition.SetWhere(fmt.Sprintf("%s >= ?", column), []interface{}{qValue.Field(i).Interface()})
 		return
 	case LT:
-		condition.SetWhere(fmt.Sprintf("%s.%s < ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+		condition.SetWhere(fmt.Sprintf("%s < ?", column), []interface{}{qValue.Field(i).Interface()})
 		return
 	case LTE:
-		condition.SetWhere(fmt.Sprintf("%s.%s <= ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+		condition.SetWhere(fmt.Sprintf("%s <= ?", column), []interface{}{qValue.Field(i).Interface()})
 		return
-	case ILEFT:
-		condition.SetWhere(fmt.Sprintf("%s.%s ilike ?", t.Table, t.Column), []interface{}{qValue.Field(i).String() + "%"})
+	case LIKE:
+		condition.SetWhere(fmt.Sprintf("%s like ?", column), []interface{}{"%" + qValue.Field(i).String() + "%"})
+		return
+	case ILIKE:
+		condition.SetWhere(d.ILike(column), []interface{}{"%" + qValue.Field(i).String() + "%"})
 		return
 	case LEFT:
-		condition.SetWhere(fmt.Sprintf("%s.%s like ?", t.Table, t.Column), []interface{}{qValue.Field(i).String() + "%"})
+		condition.SetWhere(fmt.Sprintf("%s like ?", column), []interface{}{qValue.Field(i).String() + "%"})
 		return
-	case IRIGHT:
-		condition.SetWhere(fmt.Sprintf("%s.%s ilike ?", t.Table, t.Column), []interface{}{"%" + qValue.Field(i).String()})
+	case ILEFT:
+		condition.SetWhere(d.ILike(column), []interface{}{qValue.Field(i).String() + "%"})
 		return
 	case RIGHT:
-		condition.SetWhere(fmt.Sprintf("%s.%s like ?", t.Table, t.Column), []interface{}{"%" + qValue.Field(i).String()})
+		condition.SetWhere(fmt.Sprintf("%s like ?", column), []interface{}{"%" + qValue.Field(i).String()})
+		return
+	case IRIGHT:
+		condition.SetWhere(d.ILike(column), []interface{}{"%" + qValue.Field(i).String()})
 		return
 	case IN:
-		condition.SetWhere(fmt.Sprintf("%s.%s in (?)", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+		condition.SetWhere(fmt.Sprintf("%s in (?)", column), []interface{}{qValue.Field(i).Interface()})
 		return
-	case ISNULL:
-		if !(qValue.Field(i).IsZero() && qValue.Field(i).IsNil()) {
-			condition.SetWhere(fmt.Sprintf("%s.%s is null", t.Table, t.Column), make([]interface{}, 0))
+	case NOTIN:
+		if k := qValue.Field(i).Kind(); k != reflect.Slice && k != reflect.Array {
+			setErr(condition, fmt.Errorf("query field %s: notin requires a slice", t.Column))
+			return
 		}
-		return
-	case ISNOTNULL:
-		if !(qValue.Field(i).IsZero() && qValue.Field(i).IsNil()) {
-			condition.SetWhere(fmt.Sprintf("%s.%s is not null", t.Table, t.Column), make([]interface{}, 0))
+		if qValue.Field(i).Len() > 0 {
+			condition.SetWhere(fmt.Sprintf("%s not in (?)", column), []interface{}{qValue.Field(i).Interface()})
 		}
 		return
-	case ORDER:
-		switch strings.ToLower(qValue.Field(i).String()) {
-		case "desc", "asc":
-			condition.SetOrder(fmt.Sprintf("%s.%s %s", t.Table, t.Column, qValue.Field(i).String()))
+	case BETWEEN:
+		if sql, args, ok := betweenSql(column, qValue.Field(i)); ok {
+			condition.SetWhere(sql, args)
 		}
 		return
-	case JOIN:
-		//左关联
-		join := condition.SetJoinOn(t.Type, fmt.Sprintf(
-			"left join %s on %s.%s = %s.%s", t.Join, t.Join, t.On[0], t.Table, t.On[1],
-		))
-		ResolveSearchQuery(driver, qValue.Field(i).Interface(), join, tname)
-		return
-	default:
-		condition.SetWhere(fmt.Sprintf("%s.%s = ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
-	}
-}
-
-func otherSql(driver string, t *resolveSearchTag, condition Condition, qValue reflect.Value, i int, tname string) {
-	if t.Type == "" {
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` = ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
-		return
-	}
-	qtag := QueryTag(t.Type)
-	switch qtag {
-	case EQ:
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` = ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
-		return
-	case GT:
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` > ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
-		return
-	case GTE:
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` >= ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
-		return
-	case LT:
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` < ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
-		return
-	case LTE:
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` <= ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
-		return
-	case LEFT:
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` like ?", t.Table, t.Column), []interface{}{qValue.Field(i).String() + "%"})
-		return
-	case LIKE:
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` like ?", t.Table, t.Column), []interface{}{"%" + qValue.Field(i).String() + "%"})
+	case JSON:
+		if sql, args, ok := jsonSql(d, column, t.Path, qValue.Field(i)); ok {
+			condition.SetWhere(sql, args)
+		}
 		return
-	case RIGHT:
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` like ?", t.Table, t.Column), []interface{}{"%" + qValue.Field(i).String()})
-		return
-	case IN:
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` in (?)", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+	case MATCH:
+		condition.SetWhere(d.Match(column), []interface{}{qValue.Field(i).Interface()})
 		return
 	case ISNULL:
 		if !(qValue.Field(i).IsZero() && qValue.Field(i).IsNil()) {
-			condition.SetWhere(fmt.Sprintf("`%s`.`%s` is null", t.Table, t.Column), make([]interface{}, 0))
+			condition.SetWhere(fmt.Sprintf("%s is null", column), make([]interface{}, 0))
 		}
 		return
 	case ISNOTNULL:
 		if !(qValue.Field(i).IsZero() && qValue.Field(i).IsNil()) {
-			condition.SetWhere(fmt.Sprintf("%s.%s is not null", t.Table, t.Column), make([]interface{}, 0))
+			condition.SetWhere(fmt.Sprintf("%s is not null", column), make([]interface{}, 0))
 		}
 		return
 	case ORDER:
-
 		val := strings.TrimSpace(qValue.Field(i).String())
-		if val != "" {
-			column, order, success := parseOrder(val)
-			column = CameCaseToUnderscore(column)
-			is := detectSQLInjection(column)
+		if key, order, success := parseOrder(val); success {
+			key = CameCaseToUnderscore(key)
 			order = castOrder(order)
-			if success && !is {
-				condition.SetOrder(fmt.Sprintf("`%s`.`%s` %s", t.Table, column, order))
-			} else {
-				switch strings.ToLower(qValue.Field(i).String()) {
-				case "desc", "asc":
-					condition.SetOrder(fmt.Sprintf("`%s`.`%s` %s", t.Table, t.Column, qValue.Field(i).String()))
-				}
-			}
-		} else {
-			switch strings.ToLower(qValue.Field(i).String()) {
-			case "desc", "asc":
-				condition.SetOrder(fmt.Sprintf("`%s`.`%s` %s", t.Table, t.Column, qValue.Field(i).String()))
+			if !detectSQLInjection(key) && order != "" {
+				condition.SetOrder(fmt.Sprintf("%s %s", d.Column(t.Table, key), order))
 			}
+			return
+		}
+		if order := castOrder(val); order != "" {
+			condition.SetOrder(fmt.Sprintf("%s %s", column, order))
+		}
+		return
+	case SORT:
+		items, err := parseSort(qValue.Field(i).String(), t)
+		if err != nil {
+			setErr(condition, err)
+			return
+		}
+		for _, item := range items {
+			condition.SetOrder(item.sql(d, t.Table))
 		}
 		return
 	case JOIN:
 		//左关联
 		join := condition.SetJoinOn(t.Type, fmt.Sprintf(
-			"left join `%s` on `%s`.`%s` = `%s`.`%s`",
-			t.Join,
-			t.Join,
-			t.On[0],
-			t.Table,
-			t.On[1],
+			"left join %s on %s = %s",
+			d.Quote(t.Join),
+			d.Column(t.Join, t.On[0]),
+			d.Column(t.Table, t.On[1]),
 		))
+		if j, ok := join.(*GormJoin); ok {
+			j.Table = t.Join
+		}
 		ResolveSearchQuery(driver, qValue.Field(i).Interface(), join, tname)
 		return
 	default:
-		condition.SetWhere(fmt.Sprintf("`%s`.`%s` = ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+		condition.SetWhere(fmt.Sprintf("%s = ?", column), []interface{}{qValue.Field(i).Interface()})
+	}
+}
+
+// betweenSql 区间条件，v 为两个元素的切片或数组，单边为零值时退化为 >= 或 <=
+func betweenSql(column string, v reflect.Value) (string, []interface{}, bool) {
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Len() != 2 {
+		return "", nil, false
+	}
+	start, end := v.Index(0), v.Index(1)
+	switch {
+	case start.IsZero() && end.IsZero():
+		return "", nil, false
+	case start.IsZero():
+		return fmt.Sprintf("%s <= ?", column), []interface{}{end.Interface()}, true
+	case end.IsZero():
+		return fmt.Sprintf("%s >= ?", column), []interface{}{start.Interface()}, true
+	default:
+		return fmt.Sprintf("%s between ? and ?", column), []interface{}{start.Interface(), end.Interface()}, true
 	}
 }
 
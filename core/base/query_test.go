@@ -3,7 +3,9 @@ package base
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 )
 
 type TP struct {
@@ -138,3 +140,310 @@ func TestMakeTag(t *testing.T) {
 	// 	})
 	// }
 }
+
+type GroupQuery struct {
+	Status  int    `json:"status" query:"type:eq"`
+	Title   string `json:"title" query:"type:like;group:kw;logic:or"`
+	Content string `json:"content" query:"type:like;group:kw"`
+	Author  string `json:"author" query:"group:kw.au"`
+	Editor  string `json:"editor" query:"group:kw.au"`
+}
+
+func (GroupQuery) TableName() string {
+	return "article"
+}
+
+func TestResolveSearchQueryGroup(t *testing.T) {
+	q := GroupQuery{
+		Status:  1,
+		Title:   "go",
+		Content: "go",
+		Author:  "a",
+		Editor:  "e",
+	}
+	tests := []struct {
+		driver string
+		want   string
+	}{
+		{"mysql", "(`article`.`title` like ? OR `article`.`content` like ? OR (`article`.`author` = ? AND `article`.`editor` = ?))"},
+		{"pgsql", `(article.title like ? OR article.content like ? OR (article.author = ? AND article.editor = ?))`},
+		{"mssql", "([article].[title] like ? OR [article].[content] like ? OR ([article].[author] = ? AND [article].[editor] = ?))"},
+	}
+	for _, test := range tests {
+		condition := &GormCondition{
+			GormPublic: GormPublic{},
+			Join:       make([]*GormJoin, 0),
+		}
+		ResolveSearchQuery(test.driver, q, condition, "")
+		if len(condition.Where) != 2 {
+			t.Fatalf("%s: where size %d, want 2", test.driver, len(condition.Where))
+		}
+		args, ok := condition.Where[test.want]
+		if !ok {
+			t.Fatalf("%s: group condition not found %v", test.driver, condition.Where)
+		}
+		want := []interface{}{"%go%", "%go%", "a", "e"}
+		if !reflect.DeepEqual(args, want) {
+			t.Errorf("%s: args = %v, want %v", test.driver, args, want)
+		}
+	}
+}
+
+func TestResolveSearchQueryEmptyGroup(t *testing.T) {
+	condition := &GormCondition{
+		GormPublic: GormPublic{},
+		Join:       make([]*GormJoin, 0),
+	}
+	ResolveSearchQuery("mysql", GroupQuery{Status: 1}, condition, "")
+	if len(condition.Where) != 1 {
+		t.Errorf("where = %v, want only status", condition.Where)
+	}
+}
+
+type OpQuery struct {
+	Status  int         `json:"status" query:"type:ne"`
+	Ids     []int       `json:"ids" query:"type:notin;column:id"`
+	Age     []int       `json:"age" query:"type:between"`
+	Created []time.Time `json:"created" query:"type:between;column:created_at"`
+	Tags    string      `json:"tags" query:"type:json;path:$.tags"`
+	Attrs   []string    `json:"attrs" query:"type:json"`
+	Content string      `json:"content" query:"type:match"`
+}
+
+func (OpQuery) TableName() string {
+	return "t"
+}
+
+type NotInScalarQuery struct {
+	Id int `json:"id" query:"type:notin"`
+}
+
+func (NotInScalarQuery) TableName() string {
+	return "t"
+}
+
+func TestNotInScalar(t *testing.T) {
+	condition := &GormCondition{
+		GormPublic: GormPublic{},
+		Join:       make([]*GormJoin, 0),
+	}
+	ResolveSearchQuery("mysql", NotInScalarQuery{Id: 1}, condition, "t")
+	if condition.Err == nil || len(condition.Where) != 0 {
+		t.Errorf("notin on a scalar should fail, err %v where %v", condition.Err, condition.Where)
+	}
+}
+
+func TestResolveSearchQueryOperators(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := OpQuery{
+		Status:  2,
+		Ids:     []int{1, 2},
+		Age:     []int{18, 30},
+		Created: []time.Time{start, {}},
+		Tags:    "go",
+		Attrs:   []string{"a", "b"},
+		Content: "hello",
+	}
+	tests := []struct {
+		driver string
+		want   map[string][]interface{}
+	}{
+		{"mysql", map[string][]interface{}{
+			"`t`.`status` <> ?":                                          {2},
+			"`t`.`id` not in (?)":                                        {[]int{1, 2}},
+			"`t`.`age` between ? and ?":                                  {18, 30},
+			"`t`.`created_at` >= ?":                                      {start},
+			"JSON_CONTAINS(`t`.`tags`, ?, '$.tags')":                     {`"go"`},
+			"JSON_CONTAINS(`t`.`attrs`, ?, '$')":                         {`["a","b"]`},
+			"MATCH (`t`.`content`) AGAINST (? IN NATURAL LANGUAGE MODE)": {"hello"},
+		}},
+		{"pgsql", map[string][]interface{}{
+			`t.status <> ?`:                                {2},
+			`t.id not in (?)`:                              {[]int{1, 2}},
+			`t.age between ? and ?`:                        {18, 30},
+			`t.created_at >= ?`:                            {start},
+			`(t.tags::jsonb #> '{tags}') @> ?::jsonb`:      {`"go"`},
+			`t.attrs::jsonb @> ?::jsonb`:                   {`["a","b"]`},
+			`to_tsvector(t.content) @@ plainto_tsquery(?)`: {"hello"},
+		}},
+		{"sqlite", map[string][]interface{}{
+			"`t`.`status` <> ?":         {2},
+			"`t`.`id` not in (?)":       {[]int{1, 2}},
+			"`t`.`age` between ? and ?": {18, 30},
+			"`t`.`created_at` >= ?":     {start},
+			"EXISTS (SELECT 1 FROM json_each(`t`.`tags`, '$.tags') WHERE json_each.value = ?)":                                                                                {"go"},
+			"(EXISTS (SELECT 1 FROM json_each(`t`.`attrs`, '$') WHERE json_each.value = ?) AND EXISTS (SELECT 1 FROM json_each(`t`.`attrs`, '$') WHERE json_each.value = ?))": {"a", "b"},
+			"`t`.`content` MATCH ?": {"hello"},
+		}},
+		{"mssql", map[string][]interface{}{
+			"[t].[status] <> ?":         {2},
+			"[t].[id] not in (?)":       {[]int{1, 2}},
+			"[t].[age] between ? and ?": {18, 30},
+			"[t].[created_at] >= ?":     {start},
+			"EXISTS (SELECT 1 FROM OPENJSON([t].[tags], '$.tags') WHERE [value] = ?)":                                                                       {"go"},
+			"(EXISTS (SELECT 1 FROM OPENJSON([t].[attrs], '$') WHERE [value] = ?) AND EXISTS (SELECT 1 FROM OPENJSON([t].[attrs], '$') WHERE [value] = ?))": {"a", "b"},
+			"FREETEXT([t].[content], ?)": {"hello"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.driver, func(t *testing.T) {
+			condition := &GormCondition{
+				GormPublic: GormPublic{},
+				Join:       make([]*GormJoin, 0),
+			}
+			ResolveSearchQuery(test.driver, q, condition, "")
+			if !reflect.DeepEqual(condition.Where, test.want) {
+				t.Errorf("where = %v\nwant %v", condition.Where, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveSearchQuerySkipEmptyOperators(t *testing.T) {
+	q := OpQuery{
+		Ids:     []int{},
+		Age:     []int{0, 0},
+		Created: []time.Time{{}},
+	}
+	condition := &GormCondition{
+		GormPublic: GormPublic{},
+		Join:       make([]*GormJoin, 0),
+	}
+	ResolveSearchQuery("mysql", q, condition, "")
+	if len(condition.Where) != 0 {
+		t.Errorf("where = %v, want empty", condition.Where)
+	}
+}
+
+type DialectJoinQuery struct {
+	Name string `json:"name" query:"type:ilike"`
+}
+
+func (DialectJoinQuery) TableName() string {
+	return "dept"
+}
+
+type DialectQuery struct {
+	Name  string           `json:"name" query:"type:ilike"`
+	Sort  string           `json:"sort" query:"type:order;column:id"`
+	Dept  DialectJoinQuery `json:"dept" query:"type:join;join:dept;on:id:dept_id"`
+	Email string           `json:"email" query:"type:iright"`
+}
+
+func (DialectQuery) TableName() string {
+	return "sys.user"
+}
+
+func TestResolveSearchQueryDialect(t *testing.T) {
+	q := DialectQuery{
+		Name:  "Tom",
+		Sort:  "DESC",
+		Dept:  DialectJoinQuery{Name: "Dev"},
+		Email: "@x.com",
+	}
+	tests := []struct {
+		driver string
+		where  []string
+		order  string
+		join   string
+	}{
+		{"mysql", []string{"LOWER(`sys`.`user`.`name`) like LOWER(?)", "LOWER(`sys`.`user`.`email`) like LOWER(?)"}, "`sys`.`user`.`id` desc", "left join `dept` on `dept`.`id` = `sys`.`user`.`dept_id`"},
+		{"pgsql", []string{`sys."user".name ilike ?`, `sys."user".email ilike ?`}, `sys."user".id desc`, `left join dept on dept.id = sys."user".dept_id`},
+		{"sqlite", []string{"`sys`.`user`.`name` like ?", "`sys`.`user`.`email` like ?"}, "`sys`.`user`.`id` desc", "left join `dept` on `dept`.`id` = `sys`.`user`.`dept_id`"},
+		{"mssql", []string{"LOWER([sys].[user].[name]) like LOWER(?)", "LOWER([sys].[user].[email]) like LOWER(?)"}, "[sys].[user].[id] desc", "left join [dept] on [dept].[id] = [sys].[user].[dept_id]"},
+	}
+	for _, test := range tests {
+		t.Run(test.driver, func(t *testing.T) {
+			condition := &GormCondition{
+				GormPublic: GormPublic{},
+				Join:       make([]*GormJoin, 0),
+			}
+			ResolveSearchQuery(test.driver, q, condition, "")
+			for _, w := range test.where {
+				if _, ok := condition.Where[w]; !ok {
+					t.Errorf("where %s not found in %v", w, condition.Where)
+				}
+			}
+			if len(condition.Order) != 1 || condition.Order[0] != test.order {
+				t.Errorf("order = %v, want %s", condition.Order, test.order)
+			}
+			if len(condition.Join) != 1 || condition.Join[0].JoinOn != test.join {
+				t.Fatalf("join = %v, want %s", condition.Join, test.join)
+			}
+			if len(condition.Join[0].Where) != 1 {
+				t.Errorf("join where = %v", condition.Join[0].Where)
+			}
+		})
+	}
+}
+
+type SortQuery struct {
+	Status int    `json:"status" query:"type:eq"`
+	Sort   string `json:"sort" query:"type:sort;fields:createdAt=created_at,name,deptName=dept.name;default:-createdAt"`
+}
+
+func (SortQuery) TableName() string {
+	return "user"
+}
+
+func TestResolveSearchQuerySort(t *testing.T) {
+	tests := []struct {
+		sort  string
+		want  []string
+		isErr bool
+	}{
+		{"-createdAt,name", []string{"`user`.`created_at` desc", "`user`.`name` asc"}, false},
+		{" +name , -deptName ", []string{"`user`.`name` asc", "`dept`.`name` desc"}, false},
+		{"", []string{"`user`.`created_at` desc"}, false},
+		{"name,name", []string{"`user`.`name` asc"}, false},
+		{"password", nil, true},
+		{"name;drop table user", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.sort, func(t *testing.T) {
+			q := SortQuery{Sort: test.sort}
+			condition := &GormCondition{
+				GormPublic: GormPublic{},
+				Join:       make([]*GormJoin, 0),
+			}
+			ResolveSearchQuery("mysql", q, condition, "")
+			if test.isErr {
+				if condition.Err == nil || ValidQuery(q) == nil {
+					t.Errorf("sort %q want err", test.sort)
+				}
+				if len(condition.Order) != 0 {
+					t.Errorf("order = %v, want empty", condition.Order)
+				}
+				return
+			}
+			if condition.Err != nil {
+				t.Fatalf("unexpected err %v", condition.Err)
+			}
+			if !reflect.DeepEqual(condition.Order, test.want) {
+				t.Errorf("order = %v, want %v", condition.Order, test.want)
+			}
+		})
+	}
+}
+
+func TestPgQuote(t *testing.T) {
+	d := GetDialect(Postgres)
+	tests := map[string]string{
+		"ExampleQuery":       "ExampleQuery",
+		"sys.ExampleQuery":   "sys.ExampleQuery",
+		"created_at":         "created_at",
+		"user":               `"user"`,
+		"Order":              `"Order"`,
+		"my-table":           `"my-table"`,
+		`a"b`:                `"a""b"`,
+		"public.order_items": "public.order_items",
+	}
+	for name, want := range tests {
+		if got := d.Quote(name); got != want {
+			t.Errorf("Quote(%s) = %s, want %s", name, got, want)
+		}
+	}
+	if got := d.Column("ExampleQuery", "userName"); got != "ExampleQuery.userName" {
+		t.Errorf("Column = %s", got)
+	}
+}
@@ -1,10 +1,14 @@
 package base
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/mooncake9527/npx/core"
 	"github.com/mooncake9527/npx/core/cache"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 func NewDao(dbname string) *BaseDao {
@@ -15,13 +19,69 @@ func NewDao(dbname string) *BaseDao {
 
 type BaseDao struct {
 	DbName string
+	ctx    context.Context
+}
+
+/*
+* 绑定上下文，租户隔离、操作人等从上下文获取
+* e.g. dao.WithContext(c).Query(req, &list)
+ */
+func (s *BaseDao) WithContext(ctx context.Context) *BaseDao {
+	return &BaseDao{
+		DbName: s.DbName,
+		ctx:    ctx,
+	}
 }
 
 /*
 * 获取数据库
  */
 func (s *BaseDao) DB() *gorm.DB {
-	return core.Db(s.DbName)
+	if s.ctx == nil {
+		db, err := core.GetDb(s.DbName)
+		if err != nil {
+			return errDb(err)
+		}
+		return db
+	}
+	//按租户路由，失败时返回带错误的db，后续操作直接返回该错误
+	db, err := core.TenantDb(s.ctx, s.DbName)
+	if err != nil {
+		if db, _ = core.GetDb(s.DbName); db == nil {
+			return errDb(err).WithContext(s.ctx)
+		}
+		db = db.Session(&gorm.Session{})
+		_ = db.AddError(err)
+	}
+	return db.WithContext(s.ctx)
+}
+
+// errDb 无可用db时返回带错误的db，后续操作直接返回该错误
+func errDb(err error) *gorm.DB {
+	db, _ := gorm.Open(nil, &gorm.Config{Logger: logger.Discard})
+	_ = db.AddError(err)
+	return db
+}
+
+/*
+* 获取读库，有健康的只读副本时按权重选择，本次请求已写过或无副本时使用主库
+ */
+func (s *BaseDao) ReadDB() *gorm.DB {
+	if db := core.ReplicaDb(s.ctx, s.DbName); db != nil {
+		if s.ctx != nil {
+			return db.WithContext(s.ctx)
+		}
+		return db
+	}
+	return s.DB()
+}
+
+/*
+* 事务，事务内及之后的读都走主库
+ */
+func (s *BaseDao) Transaction(fn func(tx *gorm.DB) error) error {
+	core.MarkWritten(s.ctx)
+	return s.DB().Transaction(fn)
 }
 
 /*
@@ -170,7 +230,7 @@ func (s *BaseDao) DelIdsTx(tx *gorm.DB, model any, ids any) error {
 * 根据id获取模型
  */
 func (s *BaseDao) Get(id any, model any) error {
-	if err := s.DB().First(model, id).Error; err != nil {
+	if err := s.ReadDB().First(model, id).Error; err != nil {
 		return errors.New(err.Error())
 	}
 	return nil
@@ -182,7 +242,7 @@ func (s *BaseDao) Get(id any, model any) error {
 * models: 代表查询返回的model数组
  */
 func (s *BaseDao) GetByWhere(where any, models any) error {
-	if err := s.DB().Where(where).Find(models).Error; err != nil {
+	if err := s.ReadDB().Where(where).Find(models).Error; err != nil {
 		return errors.New(err.Error())
 	}
 	return nil
@@ -194,7 +254,7 @@ func (s *BaseDao) GetByWhere(where any, models any) error {
 * models: 代表查询返回的model数组
  */
 func (s *BaseDao) GetByMap(where map[string]any, models any) error {
-	if err := s.DB().Where(where).Find(models).Error; err != nil {
+	if err := s.ReadDB().Where(where).Find(models).Error; err != nil {
 		return errors.New(err.Error())
 	}
 	return nil
@@ -206,7 +266,7 @@ func (s *BaseDao) GetByMap(where map[string]any, models any) error {
 * count: 查询条数
  */
 func (s *BaseDao) Count(model any, count *int64) error {
-	if err := s.DB().Model(model).Where(model).Count(count).Error; err != nil {
+	if err := s.ReadDB().Model(model).Where(model).Count(count).Error; err != nil {
 		return errors.New(err.Error())
 	}
 	return nil
@@ -218,7 +278,7 @@ func (s *BaseDao) Count(model any, count *int64) error {
 * count: 查询条数
  */
 func (s *BaseDao) CountByMap(where map[string]any, model any, count *int64) error {
-	if err := s.DB().Model(model).Where(where).Count(count).Error; err != nil {
+	if err := s.ReadDB().Model(model).Where(where).Count(count).Error; err != nil {
 		return errors.New(err.Error())
 	}
 	return nil
@@ -229,7 +289,7 @@ func (s *BaseDao) CountByMap(where map[string]any, model any, count *int64) erro
 * where 实现Query接口
  */
 func (s *BaseDao) Query(where Query, models any) error {
-	if err := s.DB().Scopes(s.MakeCondition(where)).Find(models).Error; err != nil {
+	if err := s.ReadDB().Scopes(s.MakeCondition(where)).Find(models).Error; err != nil {
 		return errors.New(err.Error())
 	}
 	return nil
@@ -237,7 +297,7 @@ func (s *BaseDao) Query(where Query, models any) error {
 
 // Page 分页查询
 func (s *BaseDao) Page(where Query, models any, limit, offset int) error {
-	if err := s.DB().Scopes(s.MakeCondition(where)).Limit(limit).Offset(offset).Find(models).Error; err != nil {
+	if err := s.ReadDB().Scopes(s.MakeCondition(where)).Limit(limit).Offset(offset).Find(models).Error; err != nil {
 		return errors.New(err.Error())
 	}
 	return nil
@@ -247,7 +307,7 @@ func (s *BaseDao) Page(where Query, models any, limit, offset int) error {
 * 分页获取
  */
 func (s *BaseDao) QPage(where any, data any, total *int64, limit, offset int) error {
-	if err := s.DB().Where(where).Limit(limit).Offset(offset).
+	if err := s.ReadDB().Where(where).Limit(limit).Offset(offset).
 		Find(data).Limit(-1).Offset(-1).Count(total).Error; err != nil {
 		return errors.New(err.Error())
 	}
@@ -258,7 +318,7 @@ func (s *BaseDao) QPage(where any, data any, total *int64, limit, offset int) er
 * 分页获取
  */
 func (s *BaseDao) QueryPage(where Query, models any, total *int64, limit, offset int) error {
-	if err := s.DB().Scopes(s.MakeCondition(where)).Limit(limit).Offset(offset).
+	if err := s.ReadDB().Scopes(s.MakeCondition(where)).Limit(limit).Offset(offset).
 		Find(models).Limit(-1).Offset(-1).Count(total).Error; err != nil {
 		return errors.New(err.Error())
 	}
@@ -312,12 +372,33 @@ func (s *BaseDao) MakeCondition(q Query) func(db *gorm.DB) *gorm.DB {
 			GormPublic: GormPublic{},
 			Join:       make([]*GormJoin, 0),
 		}
-		ResolveSearchQuery(core.Cfg.DBCfg.GetDriver(s.DbName), q, condition, q.TableName())
+		cfg := core.GetCfg()
+		//按会话实际连接的库取方言，租户库可能与配置的驱动不同
+		driver := driverOf(db)
+		if driver == "" {
+			driver = cfg.DBCfg.GetDriver(s.DbName)
+		}
+		ResolveSearchQuery(driver, q, condition, q.TableName())
+		if condition.Err != nil {
+			_ = db.AddError(condition.Err)
+			return db
+		}
 		for _, join := range condition.Join {
 			if join == nil {
 				continue
 			}
-			db = db.Joins(join.JoinOn)
+			if join.Err != nil {
+				_ = db.AddError(join.Err)
+				return db
+			}
+			//关联表同样做租户隔离
+			tc := cfg.DBCfg.Tenant
+			if tenantId, ok := core.TenantFilter(db); ok && join.Table != "" && !tc.IsIgnore(join.Table) {
+				d := GetDialect(driver)
+				db = db.Joins(fmt.Sprintf("%s and %s = ?", join.JoinOn, d.Column(join.Table, tc.GetColumn())), tenantId)
+			} else {
+				db = db.Joins(join.JoinOn)
+			}
 			for k, v := range join.Where {
 				db = db.Where(k, v...)
 			}
@@ -0,0 +1,83 @@
+package base
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type TenantDeptQuery struct {
+	Name string `json:"name" query:"type:eq"`
+}
+
+func (TenantDeptQuery) TableName() string {
+	return "dept"
+}
+
+type TenantUserQuery struct {
+	Name string          `json:"name" query:"type:eq"`
+	Dept TenantDeptQuery `json:"dept" query:"type:join;join:dept;on:id:dept_id"`
+}
+
+func (TenantUserQuery) TableName() string {
+	return "user"
+}
+
+type tenantUser struct {
+	Id       int
+	Name     string
+	DeptId   int
+	TenantId string
+}
+
+func (tenantUser) TableName() string {
+	return "user"
+}
+
+func TestMakeConditionTenantJoin(t *testing.T) {
+	core.Cfg.DBCfg.Tenant.Enable = true
+	defer func() { core.Cfg.DBCfg.Tenant.Enable = false }()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//方言以实际连接的库为准
+	core.Cfg.DBCfg.DBS = map[string]config.DB{"tenant": {Driver: Postgres}}
+	core.SetDb("tenant", db)
+
+	dao := NewDao("tenant").WithContext(core.WithTenantId(context.Background(), "a"))
+	q := TenantUserQuery{Name: "u", Dept: TenantDeptQuery{Name: "d"}}
+	var list []tenantUser
+	stmt := dao.DB().Scopes(dao.MakeCondition(q)).Find(&list).Statement
+	sql := stmt.SQL.String()
+	for _, want := range []string{
+		"left join `dept` on `dept`.`id` = `user`.`dept_id` and `dept`.`tenant_id` = ?",
+		"`user`.`tenant_id` = ?",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("sql %s\nmissing %s", sql, want)
+		}
+	}
+
+	core.Cfg.DBCfg.Tenant.IgnoreTables = []string{"dept"}
+	defer func() { core.Cfg.DBCfg.Tenant.IgnoreTables = nil }()
+	sql = dao.DB().Scopes(dao.MakeCondition(q)).Find(&list).Statement.SQL.String()
+	if strings.Contains(sql, "`dept`.`tenant_id`") {
+		t.Errorf("ignored join table should not be filtered: %s", sql)
+	}
+}
+
+func TestDaoMissingDb(t *testing.T) {
+	var list []tenantUser
+	for _, dao := range []*BaseDao{NewDao("missing"), NewDao("missing").WithContext(context.Background())} {
+		if err := dao.Query(TenantUserQuery{}, &list); err == nil || !strings.Contains(err.Error(), core.ErrDbNotFound.Error()) {
+			t.Errorf("missing db err %v", err)
+		}
+	}
+}
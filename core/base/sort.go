@@ -0,0 +1,98 @@
+package base
+
+import (
+	"fmt"
+	"strings"
+)
+
+type sortItem struct {
+	table  string
+	column string
+	desc   bool
+}
+
+func (s sortItem) sql(d Dialect, table string) string {
+	if s.table != "" {
+		table = s.table
+	}
+	if s.desc {
+		return d.Column(table, s.column) + " desc"
+	}
+	return d.Column(table, s.column) + " asc"
+}
+
+// parseSortFields 解析排序白名单 e.g. createdAt=created_at,name,deptName=dept.name
+// 未指定列名时取字段名的下划线形式
+func parseSortFields(fields string) map[string]sortItem {
+	r := make(map[string]sortItem)
+	for _, f := range strings.Split(fields, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		key, column, ok := strings.Cut(f, "=")
+		if !ok {
+			column = CameCaseToUnderscore(key)
+		}
+		item := sortItem{column: strings.TrimSpace(column)}
+		if idx := strings.LastIndex(item.column, "."); idx > 0 {
+			item.table, item.column = item.column[:idx], item.column[idx+1:]
+		}
+		r[strings.TrimSpace(key)] = item
+	}
+	return r
+}
+
+// parseSort 解析排序参数 e.g. -createdAt,name，"-" 倒序 "+" 或不填正序
+// 为空时使用 default，字段不在白名单内返回错误
+func parseSort(val string, t *resolveSearchTag) ([]sortItem, error) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		val = t.Default
+	}
+	if val == "" {
+		return nil, nil
+	}
+	fields := parseSortFields(t.Fields)
+	items := make([]sortItem, 0)
+	used := make(map[string]bool)
+	for _, key := range strings.Split(val, ",") {
+		key = strings.TrimSpace(key)
+		desc := false
+		if strings.HasPrefix(key, "-") {
+			desc = true
+			key = key[1:]
+		} else {
+			key = strings.TrimPrefix(key, "+")
+		}
+		if key == "" || used[key] {
+			continue
+		}
+		item, ok := fields[key]
+		if !ok {
+			return nil, fmt.Errorf("sort field %s is not allowed", key)
+		}
+		item.desc = desc
+		used[key] = true
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// ValidQuery 校验查询条件，如排序字段是否在白名单内
+func ValidQuery(q Query) error {
+	condition := &GormCondition{
+		GormPublic: GormPublic{},
+		Join:       make([]*GormJoin, 0),
+	}
+	ResolveSearchQuery(Mysql, q, condition, q.TableName())
+	if condition.Err != nil {
+		return condition.Err
+	}
+	for _, join := range condition.Join {
+		if join != nil && join.Err != nil {
+			return join.Err
+		}
+	}
+	return nil
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 
+	"github.com/mooncake9527/npx/common/trace"
 	"github.com/mooncake9527/npx/config"
 )
 
@@ -23,33 +25,60 @@ type ICache interface {
 	Expire(key string, expiration time.Duration) error
 }
 
+// observer 指标开启、关闭时替换，与缓存读取并发
+var observer atomic.Pointer[func(op string, hit bool)]
+
+// SetObserver 设置读缓存（get/hget）的命中回调，用于指标统计，nil 时取消，可在运行中设置
+func SetObserver(fn func(op string, hit bool)) {
+	if fn == nil {
+		observer.Store(nil)
+		return
+	}
+	observer.Store(&fn)
+}
+
+func observe(op string, hit bool) {
+	if fn := observer.Load(); fn != nil {
+		(*fn)(op, hit)
+	}
+}
+
+// New 按配置创建缓存，redis 连接失败时 panic，需要返回错误请使用 Open
 func New(conf config.CacheCfg) ICache {
-	if conf.GetType() == "redis" {
-		arr := strings.Split(conf.Addr, ";")
-		op := &redis.UniversalOptions{
-			Addrs:    arr,
-			Password: conf.Password, // no password set
-		}
-		if conf.DB > 0 {
-			op.DB = conf.DB
-		}
-		if conf.MasterName != "" {
-			op.MasterName = conf.MasterName
-		}
-		rdb := redis.NewUniversalClient(op)
-
-		pong, err := rdb.Ping(context.Background()).Result()
-		if err != nil {
-			panic("redis connect ping failed, err:" + err.Error())
-		} else {
-			fmt.Println("redis connect ping response:", "pong", pong)
-			r := RedisCache{
-				redis:  rdb,
-				prefix: conf.Prefix,
-			}
-			return &r
-		}
-	} else {
-		return NewMemory()
+	c, err := Open(conf)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Open 按配置创建缓存，redis 连接失败时返回错误
+func Open(conf config.CacheCfg) (ICache, error) {
+	if conf.GetType() != "redis" {
+		return NewMemory(), nil
+	}
+	arr := strings.Split(conf.Addr, ";")
+	op := &redis.UniversalOptions{
+		Addrs:    arr,
+		Password: conf.Password, // no password set
+	}
+	if conf.DB > 0 {
+		op.DB = conf.DB
+	}
+	if conf.MasterName != "" {
+		op.MasterName = conf.MasterName
+	}
+	rdb := redis.NewUniversalClient(op)
+	rdb.AddHook(trace.RedisHook{})
+
+	pong, err := rdb.Ping(context.Background()).Result()
+	if err != nil {
+		_ = rdb.Close()
+		return nil, fmt.Errorf("redis connect ping failed, err: %w", err)
 	}
+	fmt.Println("redis connect ping response:", "pong", pong)
+	return &RedisCache{
+		redis:  rdb,
+		prefix: conf.Prefix,
+	}, nil
 }
@@ -33,6 +33,9 @@ func (*Memory) Type() string {
 
 func (m *Memory) Get(key string) (string, error) {
 	item, err := m.getItem(key)
+	if err == nil {
+		observe("get", item != nil)
+	}
 	if err != nil || item == nil {
 		return "", err
 	}
@@ -94,6 +97,9 @@ func (m *Memory) del(key string) error {
 
 func (m *Memory) HGet(hk, key string) (string, error) {
 	item, err := m.getItem(hk + key)
+	if err == nil {
+		observe("hget", item != nil)
+	}
 	if err != nil || item == nil {
 		return "", err
 	}
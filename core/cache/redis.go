@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -10,11 +11,35 @@ import (
 type RedisCache struct {
 	redis  redis.UniversalClient
 	prefix string
+	ctx    context.Context
 	//mode  int8 //1 单机 2 cluster
 	//clusterClient *redis.ClusterClient
 
 }
 
+// WithContext 返回使用 ctx 执行命令的副本，ctx 中有链路时记录redis子span
+func (c *RedisCache) WithContext(ctx context.Context) *RedisCache {
+	r := *c
+	r.ctx = ctx
+	return &r
+}
+
+func (c *RedisCache) context() context.Context {
+	if c.ctx == nil {
+		return context.TODO()
+	}
+	return c.ctx
+}
+
+// observeResult redis.Nil 为未命中，其他错误不计入
+func observeResult(op string, err error) {
+	if err == nil {
+		observe(op, true)
+	} else if errors.Is(err, redis.Nil) {
+		observe(op, false)
+	}
+}
+
 func (c *RedisCache) Type() string {
 	return "redis"
 }
@@ -23,58 +48,67 @@ func (c *RedisCache) Get(key string) (string, error) {
 	if c.prefix != "" {
 		key = c.prefix + ":" + key
 	}
-	return c.redis.Get(context.TODO(), key).Result()
+	val, err := c.redis.Get(c.context(), key).Result()
+	observeResult("get", err)
+	return val, err
 }
 
 func (c *RedisCache) Set(key string, val any, expiration time.Duration) error {
 	if c.prefix != "" {
 		key = c.prefix + ":" + key
 	}
-	return c.redis.Set(context.TODO(), key, val, expiration).Err()
+	return c.redis.Set(c.context(), key, val, expiration).Err()
 }
 
 func (c *RedisCache) Del(key string) error {
 	if c.prefix != "" {
 		key = c.prefix + ":" + key
 	}
-	return c.redis.Del(context.TODO(), key).Err()
+	return c.redis.Del(c.context(), key).Err()
 }
 
 func (c *RedisCache) HGet(hk, field string) (string, error) {
 	if c.prefix != "" {
 		hk = c.prefix + ":" + hk
 	}
-	return c.redis.HGet(context.TODO(), hk, field).Result()
+	val, err := c.redis.HGet(c.context(), hk, field).Result()
+	observeResult("hget", err)
+	return val, err
 }
 
 func (c *RedisCache) HDel(hk, fields string) error {
 	if c.prefix != "" {
 		hk = c.prefix + ":" + hk
 	}
-	return c.redis.HDel(context.TODO(), hk, fields).Err()
+	return c.redis.HDel(c.context(), hk, fields).Err()
 }
 
 func (c *RedisCache) Incr(key string) error {
 	if c.prefix != "" {
 		key = c.prefix + ":" + key
 	}
-	return c.redis.Incr(context.TODO(), key).Err()
+	return c.redis.Incr(c.context(), key).Err()
 }
 
 func (c *RedisCache) Decr(key string) error {
 	if c.prefix != "" {
 		key = c.prefix + ":" + key
 	}
-	return c.redis.Decr(context.TODO(), key).Err()
+	return c.redis.Decr(c.context(), key).Err()
 }
 
 func (c *RedisCache) Expire(key string, expiration time.Duration) error {
 	if c.prefix != "" {
 		key = c.prefix + ":" + key
 	}
-	return c.redis.Expire(context.TODO(), key, expiration).Err()
+	return c.redis.Expire(c.context(), key, expiration).Err()
 }
 
 func (c *RedisCache) GetClient() redis.UniversalClient {
 	return c.redis
 }
+
+// Close 关闭 redis 连接
+func (c *RedisCache) Close() error {
+	return c.redis.Close()
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"log/slog"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	callbackControlByCreate = "npx:control_by_create"
+	callbackControlByUpdate = "npx:control_by_update"
+	skipControlByKey        = "npx:skip_control_by"
+
+	fieldCreateBy = "CreateBy"
+	fieldUpdateBy = "UpdateBy"
+)
+
+// WithoutControlBy 不自动填充 CreateBy/UpdateBy，用于系统任务
+// e.g. db.Scopes(core.WithoutControlBy).Create(&m)
+func WithoutControlBy(db *gorm.DB) *gorm.DB {
+	return db.Set(skipControlByKey, true)
+}
+
+// registerCallbacks 为db注册框架回调，重复注册会跳过
+func registerCallbacks(db *gorm.DB) {
+	if db.Callback().Create().Get(callbackControlByCreate) == nil {
+		if err := db.Callback().Create().Before("gorm:create").Register(callbackControlByCreate, controlByCreate); err != nil {
+			slog.Error("register callback err", "name", callbackControlByCreate, "err", err)
+		}
+	}
+	if db.Callback().Update().Get(callbackControlByUpdate) == nil {
+		if err := db.Callback().Update().Before("gorm:update").Register(callbackControlByUpdate, controlByUpdate); err != nil {
+			slog.Error("register callback err", "name", callbackControlByUpdate, "err", err)
+		}
+	}
+	registerTenantCallbacks(db)
+	registerWrittenCallbacks(db)
+	registerTraceCallbacks(db)
+}
+
+func skipControlBy(db *gorm.DB) bool {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return true
+	}
+	skip, ok := db.Get(skipControlByKey)
+	return ok && skip == true
+}
+
+// controlByCreate 创建时填充未设置的 CreateBy/UpdateBy
+func controlByCreate(db *gorm.DB) {
+	if skipControlBy(db) {
+		return
+	}
+	uid := GetUserId(db.Statement.Context)
+	if uid == 0 {
+		return
+	}
+	for _, name := range []string{fieldCreateBy, fieldUpdateBy} {
+		field := db.Statement.Schema.LookUpField(name)
+		if field == nil {
+			continue
+		}
+		rv := db.Statement.ReflectValue
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				setIfZero(db, field, rv.Index(i), uid)
+			}
+		case reflect.Struct:
+			setIfZero(db, field, rv, uid)
+		}
+	}
+}
+
+func setIfZero(db *gorm.DB, field *schema.Field, rv reflect.Value, val any) {
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if _, isZero := field.ValueOf(db.Statement.Context, rv); isZero {
+		_ = db.AddError(field.Set(db.Statement.Context, rv, val))
+	}
+}
+
+// controlByUpdate 更新时填充 UpdateBy
+func controlByUpdate(db *gorm.DB) {
+	if skipControlBy(db) {
+		return
+	}
+	uid := GetUserId(db.Statement.Context)
+	if uid == 0 {
+		return
+	}
+	if db.Statement.Schema.LookUpField(fieldUpdateBy) == nil {
+		return
+	}
+	db.Statement.SetColumn(fieldUpdateBy, uid, true)
+}
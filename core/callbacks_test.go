@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type controlByModel struct {
+	Id       int
+	Name     string
+	CreateBy uint64
+	UpdateBy uint64
+}
+
+func newControlByDb(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registerCallbacks(db)
+	if err := db.AutoMigrate(&controlByModel{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestControlByCallbacks(t *testing.T) {
+	db := newControlByDb(t)
+	ctx := WithUserId(context.Background(), 7)
+
+	m := controlByModel{Name: "a"}
+	if err := db.WithContext(ctx).Create(&m).Error; err != nil {
+		t.Fatal(err)
+	}
+	if m.CreateBy != 7 || m.UpdateBy != 7 {
+		t.Errorf("create stamp = %d/%d, want 7/7", m.CreateBy, m.UpdateBy)
+	}
+
+	ms := []controlByModel{{Name: "b", CreateBy: 3}, {Name: "c"}}
+	if err := db.WithContext(ctx).Create(&ms).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ms[0].CreateBy != 3 || ms[1].CreateBy != 7 {
+		t.Errorf("batch create stamp = %d/%d, want 3/7", ms[0].CreateBy, ms[1].CreateBy)
+	}
+
+	ctx = WithUserId(context.Background(), 9)
+	if err := db.WithContext(ctx).Model(&m).Updates(map[string]any{"name": "aa"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var got controlByModel
+	db.First(&got, m.Id)
+	if got.CreateBy != 7 || got.UpdateBy != 9 {
+		t.Errorf("update stamp = %d/%d, want 7/9", got.CreateBy, got.UpdateBy)
+	}
+
+	ctx = WithUserId(context.Background(), 11)
+	if err := db.WithContext(ctx).Scopes(WithoutControlBy).Model(&m).Update("name", "aaa").Error; err != nil {
+		t.Fatal(err)
+	}
+	db.First(&got, m.Id)
+	if got.UpdateBy != 9 {
+		t.Errorf("skip update stamp = %d, want 9", got.UpdateBy)
+	}
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/mooncake9527/npx/core/cache"
+	"github.com/mooncake9527/npx/core/ebus"
+	"github.com/mooncake9527/npx/core/locker"
+)
+
+// 内置组件名，自定义组件可依赖这些组件 e.g. core.WithDependsOn(core.ComponentDb)
+const (
+	ComponentLog     = "log"
+	ComponentRemote  = "remote-config"
+	ComponentCache   = "cache"
+	ComponentLocker  = "locker"
+	ComponentGrpc    = "grpc"
+	ComponentDb      = "db"
+	ComponentTrace   = "trace"
+	ComponentMetrics = "metrics"
+)
+
+// components 应用组件，包含内置组件和 RegisterComponent 注册的组件
+var components = newComponents()
+
+func newComponents() *Lifecycle {
+	l := NewLifecycle()
+	_ = l.Register(
+		NewComponent(ComponentLog, startLog, stopLog),
+		NewComponent(ComponentRemote, nil, stopRemote, WithDependsOn(ComponentLog)),
+		NewComponent(ComponentCache, startCache, stopCache, WithDependsOn(ComponentLog)),
+		NewComponent(ComponentLocker, startLocker, stopLocker, WithDependsOn(ComponentCache)),
+		NewComponent(ComponentDb, startDb, stopDb, WithDependsOn(ComponentLog, ComponentLocker)),
+		NewComponent(ComponentTrace, startTrace, nil, WithDependsOn(ComponentLog)),
+		NewComponent(ComponentMetrics, startMetrics, stopMetrics, WithDependsOn(ComponentCache, ComponentDb)),
+	)
+	return l
+}
+
+// RegisterComponent 注册自定义组件（gRPC、服务发现、定时任务等），需在 Start 前调用
+func RegisterComponent(comps ...Component) error {
+	return components.Register(comps...)
+}
+
+// Start 校验配置并按依赖顺序启动所有组件，失败时停止已启动的组件并返回错误
+func Start(ctx context.Context) error {
+	if err := Cfg.Validate(); err != nil {
+		return err
+	}
+	initCfg()
+	if err := components.Start(ctx); err != nil {
+		return err
+	}
+	ebus.EventBus.Publish(ebus.EventCoreInit)
+	if err := ebus.Publish(ctx, ebus.CoreInit{Components: components.Components()}); err != nil {
+		return errors.Join(fmt.Errorf("core init: %w", err), Stop(context.Background()))
+	}
+	return nil
+}
+
+// Stop 逆序停止所有已启动的组件
+func Stop(ctx context.Context) error {
+	return components.Stop(ctx)
+}
+
+// publish 发布事件，处理函数的错误只输出日志
+func publish[T any](event T) {
+	if err := ebus.Publish(context.Background(), event); err != nil {
+		slog.Error("event handler err", "event", fmt.Sprintf("%T", event), "err", err)
+	}
+}
+
+// stopLogLevel 取消日志级别对配置变更的订阅
+var stopLogLevel func()
+
+func startLog(context.Context) error {
+	dbLogWrite = logInit()
+	stopLogLevel = ebus.Subscribe(func(_ context.Context, c ebus.ConfigChanged) error {
+		logLevel.Set(parseLogLevel(c.New.Logger.Level))
+		return nil
+	})
+	return nil
+}
+
+func stopLog(context.Context) error {
+	if stopLogLevel != nil {
+		stopLogLevel()
+		stopLogLevel = nil
+	}
+	return nil
+}
+
+func stopRemote(context.Context) error {
+	if stopRemoteConfig != nil {
+		stopRemoteConfig()
+		stopRemoteConfig = nil
+	}
+	return nil
+}
+
+func startCache(context.Context) error {
+	c, err := cache.Open(Cfg.Cache)
+	if err != nil {
+		return err
+	}
+	Cache = c
+	return nil
+}
+
+func stopCache(context.Context) error {
+	c := Cache
+	Cache = nil
+	if closer, ok := c.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func startLocker(context.Context) error {
+	if r, ok := Cache.(*cache.RedisCache); ok {
+		RedisLock = locker.NewRedis(r.GetClient())
+		Locker = RedisLock
+	} else {
+		Locker = locker.NewMemory()
+	}
+	return nil
+}
+
+func stopLocker(context.Context) error {
+	RedisLock = nil
+	Locker = nil
+	return nil
+}
+
+// startDb 连接db，按配置执行迁移和加载数据，失败时关闭已连接的db
+func startDb(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			_ = stopDb(ctx)
+		}
+	}()
+	if err := dbInit(dbLogWrite); err != nil {
+		return err
+	}
+	if Cfg.DBCfg.Migrate {
+		if err := Migrate(ctx); err != nil {
+			slog.Error("migrate err", "err", err)
+			return err
+		}
+	}
+	if Cfg.DBCfg.Seed != "" && (Cfg.Server.Mode == ModeDev.String() || Cfg.Server.Mode == ModeTest.String()) {
+		if err := Seed(Cfg.DBCfg.Seed); err != nil {
+			slog.Error("seed err", "err", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// stopDb 并发关闭所有db，ctx 结束时不再等待排空
+func stopDb(ctx context.Context) error {
+	stopTenantJanitor()
+	stopReplicaChecker()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for key := range Dbs() {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			if err := removeDb(ctx, key); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(key)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func startTrace(context.Context) error {
+	if !Cfg.Trace.Enable {
+		return nil
+	}
+	return initTrace()
+}
+
+func startMetrics(context.Context) error {
+	if !Cfg.Metrics.Enable {
+		return nil
+	}
+	return initMetrics()
+}
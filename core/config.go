@@ -0,0 +1,107 @@
+package core
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/pkg/errors"
+)
+
+// cfgLoader 最近一次加载配置的加载器
+var cfgLoader *config.Loader
+
+// running 运行中的配置快照，Start 时由 Cfg 生成，远程配置变更时整体替换，不修改 Cfg
+var running atomic.Pointer[config.AppCfg]
+
+// GetCfg 运行中的配置，请求处理等并发场景读取配置使用，返回值只读
+// Start 之前返回 Cfg
+func GetCfg() *config.AppCfg {
+	if c := running.Load(); c != nil {
+		return c
+	}
+	return &Cfg
+}
+
+func setCfg(cfg config.AppCfg) {
+	running.Store(&cfg)
+}
+
+// initCfg Start 时生成快照，已有远程配置变更的快照时保留，直接设置 Cfg 的应用同样可以读取 extend
+func initCfg() {
+	cfg := Cfg
+	running.CompareAndSwap(nil, &cfg)
+	config.SetExtend(GetCfg().Extends)
+}
+
+// LoadConfig 加载配置文件到 Cfg，需在 Init 之前调用
+//
+// 按 server.mode 叠加环境配置 e.g. resources/config.yaml + resources/config.dev.yaml，
+// 环境变量 NPX_SERVER_PORT 与命令行参数 --server.port=8080 可覆盖任意配置项，未知配置项输出警告，配置错误时返回所有问题
+//
+// server.remote-enable 时叠加 remote 指定的 etcd、consul 配置并监听变化，变更时发布 ebus.ConfigChanged 事件
+func LoadConfig(file string, opts ...config.LoaderOption) error {
+	l := config.NewLoader(file, append([]config.LoaderOption{config.WithArgs(os.Args[1:])}, opts...)...)
+	var cfg config.AppCfg
+	if err := l.Load(&cfg); err != nil {
+		return err
+	}
+	for _, key := range l.Unknown() {
+		slog.Warn("unknown config key", "key", key, "files", l.Files())
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	Cfg = cfg
+	cfgLoader = l
+	config.SetExtend(Cfg.Extends)
+	if Cfg.Server.RemoteEnable {
+		return loadRemoteConfig(l)
+	}
+	return nil
+}
+
+// EncryptCommand 加密配置值，输出可写入配置文件的 ENC(...)，不需要 Init
+//
+//	encrypt [-key-file path] value...
+//
+// 默认使用 NPX_CONFIG_KEY、NPX_CONFIG_KEY_FILE 的密钥，RSA 时 -key-file 可以只是公钥
+// e.g. if len(os.Args) > 1 && os.Args[1] == "encrypt" { err := core.EncryptCommand(os.Args[2:]) }
+func EncryptCommand(args []string) error {
+	fs := flag.NewFlagSet("encrypt", flag.ContinueOnError)
+	keyFile := fs.String("key-file", "", "aes key or rsa key pem file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: encrypt [-key-file path] value...")
+	}
+	var c *config.Cipher
+	var err error
+	if *keyFile != "" {
+		var key []byte
+		if key, err = os.ReadFile(*keyFile); err != nil {
+			return err
+		}
+		c, err = config.NewCipher(key)
+	} else {
+		c, err = config.CipherFromEnv()
+	}
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return errors.Errorf("no key, set %s or %s", config.EnvConfigKey, config.EnvConfigKeyFile)
+	}
+	for _, v := range fs.Args() {
+		enc, err := c.Encrypt(v)
+		if err != nil {
+			return err
+		}
+		fmt.Println(enc)
+	}
+	return nil
+}
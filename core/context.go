@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+
+	"github.com/mooncake9527/npx/common/consts"
+	"github.com/spf13/cast"
+)
+
+type ctxKey string
+
+const userIdKey ctxKey = consts.UserId
+
+// WithUserId 在上下文中设置当前用户id
+func WithUserId(ctx context.Context, userId uint64) context.Context {
+	return context.WithValue(ctx, userIdKey, userId)
+}
+
+// GetUserId 获取当前用户id，兼容 gin.Context 中 c.Set(consts.UserId, id) 设置的值
+func GetUserId(ctx context.Context) uint64 {
+	if ctx == nil {
+		return 0
+	}
+	v := ctx.Value(userIdKey)
+	if v == nil {
+		v = ctx.Value(consts.UserId)
+	}
+	if v == nil {
+		return 0
+	}
+	uid, err := cast.ToUint64E(v)
+	if err != nil {
+		return 0
+	}
+	return uid
+}
+
+const (
+	tenantIdKey     ctxKey = consts.TenantId
+	ignoreTenantKey ctxKey = "ignoreTenant"
+)
+
+// WithTenantId 在上下文中设置当前租户id
+func WithTenantId(ctx context.Context, tenantId string) context.Context {
+	return context.WithValue(ctx, tenantIdKey, tenantId)
+}
+
+// GetTenantId 获取当前租户id，兼容 gin.Context 中 c.Set(consts.TenantId, id) 设置的值
+func GetTenantId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	v := ctx.Value(tenantIdKey)
+	if v == nil {
+		v = ctx.Value(consts.TenantId)
+	}
+	if v == nil {
+		return ""
+	}
+	return cast.ToString(v)
+}
+
+// IgnoreTenant 跳过租户隔离，用于管理后台等需要跨租户访问的场景
+func IgnoreTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ignoreTenantKey, true)
+}
+
+// IsIgnoreTenant 是否跳过租户隔离
+func IsIgnoreTenant(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	ignore, _ := ctx.Value(ignoreTenantKey).(bool)
+	return ignore
+}
@@ -0,0 +1,137 @@
+// Package coretest 集成测试辅助，使用 sqlite 内存库、内存缓存与进程内锁启动隔离的 core 全局状态
+//
+//	func TestUser(t *testing.T) {
+//		app := coretest.New(t, coretest.WithModels(&User{}), coretest.WithFixtures(os.DirFS("testdata"), "."))
+//		app.Engine.GET("/user/:id", api.Get)
+//		var u User
+//		app.Get("/user/1").AssertOK().Decode(&u)
+//	}
+package coretest
+
+import (
+	"fmt"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/common/consts"
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core"
+	"github.com/mooncake9527/npx/core/cache"
+	"github.com/mooncake9527/npx/core/fixtures"
+	"github.com/mooncake9527/npx/core/locker"
+	"gorm.io/gorm"
+)
+
+var seq atomic.Int64
+
+// App 隔离的测试应用，结束时自动重置全局状态并恢复 core.Cfg
+type App struct {
+	t      testing.TB
+	Engine *gin.Engine
+	Db     *gorm.DB //默认db
+	Cache  *cache.Memory
+	Locker *locker.Memory
+}
+
+type options struct {
+	dbs      []string
+	models   []any
+	fsys     fs.FS
+	paths    []string
+	mutators []func(cfg *config.AppCfg)
+}
+
+type Option func(o *options)
+
+// WithDbs 除默认db外再创建的db标识
+func WithDbs(keys ...string) Option {
+	return func(o *options) {
+		o.dbs = append(o.dbs, keys...)
+	}
+}
+
+// WithModels 在默认db上自动建表
+func WithModels(models ...any) Option {
+	return func(o *options) {
+		o.models = append(o.models, models...)
+	}
+}
+
+// WithFixtures 建表后向默认db加载数据，见 fixtures.Load
+func WithFixtures(fsys fs.FS, paths ...string) Option {
+	return func(o *options) {
+		o.fsys = fsys
+		o.paths = paths
+	}
+}
+
+// WithConfig 修改测试使用的配置，在创建db之前执行
+func WithConfig(fn func(cfg *config.AppCfg)) Option {
+	return func(o *options) {
+		o.mutators = append(o.mutators, fn)
+	}
+}
+
+// New 重置 core 全局状态并创建测试应用，失败时终止测试
+func New(t testing.TB, opts ...Option) *App {
+	t.Helper()
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	old := core.Cfg
+	core.ResetState()
+	t.Cleanup(func() {
+		core.ResetState()
+		core.Cfg = old
+	})
+
+	core.Cfg.Server.Mode = core.ModeTest.String()
+	core.Cfg.DBCfg.Driver = core.Sqlite.String()
+	core.Cfg.DBCfg.LogMode = "silent"
+	core.Cfg.DBCfg.DBS = nil
+	for _, fn := range o.mutators {
+		fn(&core.Cfg)
+	}
+
+	gin.SetMode(gin.TestMode)
+	app := &App{
+		t:      t,
+		Engine: gin.New(),
+		Cache:  cache.NewMemory(),
+		Locker: locker.NewMemory(),
+	}
+	core.SetEngine(app.Engine)
+	core.Cache = app.Cache
+	core.Locker = app.Locker
+
+	n := seq.Add(1)
+	for _, key := range append([]string{consts.DbDefault}, o.dbs...) {
+		dsn := fmt.Sprintf("file:coretest_%d_%s?mode=memory&cache=shared", n, key)
+		if err := core.AddDb(key, config.DB{DSN: dsn}); err != nil {
+			t.Fatalf("coretest: add db %s: %v", key, err)
+		}
+	}
+	app.Db = core.Db(consts.DbDefault)
+	if len(o.models) > 0 {
+		if err := app.Db.AutoMigrate(o.models...); err != nil {
+			t.Fatalf("coretest: auto migrate: %v", err)
+		}
+	}
+	if o.fsys != nil {
+		if _, err := fixtures.Load(app.Db, o.fsys, o.paths...); err != nil {
+			t.Fatalf("coretest: load fixtures: %v", err)
+		}
+	}
+	return app
+}
+
+// Reset 清空默认db中的表
+func (a *App) Reset(tables ...string) {
+	a.t.Helper()
+	if err := fixtures.Reset(a.Db, tables...); err != nil {
+		a.t.Fatalf("coretest: reset %v: %v", tables, err)
+	}
+}
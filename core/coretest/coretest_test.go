@@ -0,0 +1,84 @@
+package coretest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/core"
+	"github.com/mooncake9527/npx/core/base"
+	"github.com/mooncake9527/npx/core/ebus"
+)
+
+type item struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestApp(t *testing.T) {
+	fsys := fstest.MapFS{"items.yml": {Data: []byte("- id: 1\n  name: a\n")}}
+	app := New(t, WithModels(&item{}), WithFixtures(fsys, "items.yml"), WithDbs("other"))
+	if core.Cfg.Server.Mode != core.ModeTest.String() || core.Cache != app.Cache || core.Locker != app.Locker {
+		t.Fatal("globals not set")
+	}
+	if _, err := core.GetDb("other"); err != nil {
+		t.Fatal(err)
+	}
+
+	app.Engine.GET("/item/:id", func(c *gin.Context) {
+		var it item
+		if err := core.Db("default").First(&it, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusOK, base.Resp{Code: 404, Msg: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, base.Resp{Code: http.StatusOK, Msg: "OK", Data: it})
+	})
+	app.Engine.POST("/item", func(c *gin.Context) {
+		var it item
+		_ = c.ShouldBindJSON(&it)
+		core.Db("default").Create(&it)
+		c.JSON(http.StatusOK, base.Resp{Code: http.StatusOK, Msg: "OK", Data: it})
+	})
+
+	var it item
+	app.Get("/item/1").AssertOK().Decode(&it)
+	if it.Name != "a" {
+		t.Errorf("item %+v", it)
+	}
+	app.Get("/item/2").AssertStatus(http.StatusOK).AssertCode(404)
+	app.Post("/item", item{Id: 2, Name: "b"}).AssertOK()
+	if resp := app.Get("/item/2").Resp(); resp.Data.(map[string]any)["name"] != "b" {
+		t.Errorf("created %+v", resp)
+	}
+
+	ctx := context.Background()
+	lock, err := core.Locker.Obtain(ctx, "k", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = core.Locker.Obtain(ctx, "k", time.Minute); err == nil {
+		t.Error("lock should be held")
+	}
+	_ = lock.Release(ctx)
+
+	app.Reset("items")
+	app.Get("/item/1").AssertCode(404)
+	ebus.EventBus.Subscribe("coretest", func() {})
+}
+
+func TestIsolation(t *testing.T) {
+	// 上一个测试的db、事件订阅不应保留
+	app := New(t)
+	if _, err := core.GetDb("other"); err == nil {
+		t.Error("db from previous test should be removed")
+	}
+	if ebus.EventBus.HasCallback("coretest") {
+		t.Error("event bus should be reset")
+	}
+	if app.Db.Migrator().HasTable(&item{}) {
+		t.Error("default db should be fresh")
+	}
+}
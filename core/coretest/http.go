@@ -0,0 +1,120 @@
+package coretest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mooncake9527/npx/core/base"
+)
+
+// Do 执行请求
+func (a *App) Do(req *http.Request) *Response {
+	w := httptest.NewRecorder()
+	a.Engine.ServeHTTP(w, req)
+	return &Response{ResponseRecorder: w, t: a.t}
+}
+
+// Request body 为 string、[]byte 时原样发送，其他类型编码为 JSON
+func (a *App) Request(method, path string, body any, header ...http.Header) *Response {
+	a.t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = bytes.NewBufferString(b)
+	case []byte:
+		r = bytes.NewBuffer(b)
+	default:
+		bs, err := json.Marshal(b)
+		if err != nil {
+			a.t.Fatalf("coretest: marshal body: %v", err)
+		}
+		r = bytes.NewBuffer(bs)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, h := range header {
+		for k, vs := range h {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+	return a.Do(req)
+}
+
+func (a *App) Get(path string, header ...http.Header) *Response {
+	a.t.Helper()
+	return a.Request(http.MethodGet, path, nil, header...)
+}
+
+func (a *App) Post(path string, body any, header ...http.Header) *Response {
+	a.t.Helper()
+	return a.Request(http.MethodPost, path, body, header...)
+}
+
+// Response 响应，断言失败时终止测试
+type Response struct {
+	*httptest.ResponseRecorder
+	t testing.TB
+}
+
+// AssertStatus 断言 http 状态码
+func (r *Response) AssertStatus(status int) *Response {
+	r.t.Helper()
+	if r.Code != status {
+		r.t.Fatalf("coretest: status %d, want %d, body %s", r.Code, status, r.Body.String())
+	}
+	return r
+}
+
+// AssertCode 断言 base.Resp 的返回码
+func (r *Response) AssertCode(code int) *Response {
+	r.t.Helper()
+	if resp := r.Resp(); resp.Code != code {
+		r.t.Fatalf("coretest: code %d, want %d, msg %s", resp.Code, code, resp.Msg)
+	}
+	return r
+}
+
+// AssertOK 断言 http 200 且返回码为成功
+func (r *Response) AssertOK() *Response {
+	r.t.Helper()
+	return r.AssertStatus(http.StatusOK).AssertCode(http.StatusOK)
+}
+
+// Resp 解析为 base.Resp，Data 为 JSON 解码后的通用类型
+func (r *Response) Resp() base.Resp {
+	r.t.Helper()
+	var resp base.Resp
+	if err := json.Unmarshal(r.Body.Bytes(), &resp); err != nil {
+		r.t.Fatalf("coretest: decode resp: %v, body %s", err, r.Body.String())
+	}
+	return resp
+}
+
+// Decode 解析为 base.Resp 并将 Data 解码到 data
+func (r *Response) Decode(data any) base.Resp {
+	r.t.Helper()
+	var raw struct {
+		base.Resp
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(r.Body.Bytes(), &raw); err != nil {
+		r.t.Fatalf("coretest: decode resp: %v, body %s", err, r.Body.String())
+	}
+	if len(raw.Data) > 0 {
+		if err := json.Unmarshal(raw.Data, data); err != nil {
+			r.t.Fatalf("coretest: decode data: %v, data %s", err, raw.Data)
+		}
+	}
+	resp := raw.Resp
+	resp.Data = data
+	return resp
+}
@@ -19,58 +19,77 @@ import (
 	"gorm.io/gorm/schema"
 )
 
-func dbInit(logWrite io.Writer) {
+func dbInit(logWrite io.Writer) error {
+	dbLogWrite = logWrite
+	if Cfg.DBCfg.Metrics {
+		AddDbMetrics(GetSqlStats())
+	}
 	if Cfg.DBCfg.DSN != "" {
 		logMode := config.GetLogMode(Cfg.DBCfg.LogMode)
-		initDb(Cfg.DBCfg.Driver, Cfg.DBCfg.DSN, Cfg.DBCfg.Prefix, consts.DbDefault, logMode, Cfg.DBCfg.SlowThreshold,
+		if err := initDb(Cfg.DBCfg.Driver, Cfg.DBCfg.DSN, Cfg.DBCfg.Prefix, consts.DbDefault, logMode, Cfg.DBCfg.SlowThreshold,
+			Cfg.DBCfg.MaxIdleConns, Cfg.DBCfg.MaxOpenConns, Cfg.DBCfg.MaxLifetime, Cfg.DBCfg.Singular, Cfg.Logger.Color(), Cfg.DBCfg.IgnoreNotFound, logWrite, Cfg.DBCfg.DryRun); err != nil {
+			return err
+		}
+		initReplicas(consts.DbDefault, Cfg.DBCfg.Replicas, Cfg.DBCfg.Driver, Cfg.DBCfg.Prefix, logMode, Cfg.DBCfg.SlowThreshold,
 			Cfg.DBCfg.MaxIdleConns, Cfg.DBCfg.MaxOpenConns, Cfg.DBCfg.MaxLifetime, Cfg.DBCfg.Singular, Cfg.Logger.Color(), Cfg.DBCfg.IgnoreNotFound, logWrite, Cfg.DBCfg.DryRun)
 	}
 	for key, dbc := range Cfg.DBCfg.DBS {
 		if !dbc.Disable {
-			var logMode logger.LogLevel
-			if dbc.LogMode != "" {
-				logMode = config.GetLogMode(dbc.LogMode)
-			} else {
-				logMode = config.GetLogMode(Cfg.DBCfg.LogMode)
-			}
-			prefix := dbc.Prefix
-			if prefix == "" && Cfg.DBCfg.Prefix != "" {
-				prefix = Cfg.DBCfg.Prefix
-			}
-			slow := dbc.SlowThreshold
-			if slow < 1 && Cfg.DBCfg.SlowThreshold > 0 {
-				slow = Cfg.DBCfg.SlowThreshold
-			}
-			singular := Cfg.DBCfg.Singular
-			maxIdle := dbc.MaxIdleConn
-			if maxIdle < 1 {
-				maxIdle = Cfg.DBCfg.GetMaxIdleConn()
-			}
-
-			maxOpen := dbc.MaxOpenConn
-			if maxOpen < 1 {
-				maxOpen = Cfg.DBCfg.GetMaxOpenConn()
-			}
-
-			maxLifetime := dbc.MaxLifetime
-			if maxLifetime < 1 {
-				maxLifetime = Cfg.DBCfg.GetMaxLifetime()
-			}
-			driver := dbc.Driver
-			if driver == "" && Cfg.DBCfg.Driver != "" {
-				driver = Cfg.DBCfg.Driver
+			dbc = mergeDbCfg(dbc)
+			logMode := config.GetLogMode(dbc.LogMode)
+			if err := initDb(dbc.Driver, dbc.DSN, dbc.Prefix, key, logMode, dbc.SlowThreshold, dbc.MaxIdleConn, dbc.MaxOpenConn, dbc.MaxLifetime,
+				Cfg.DBCfg.Singular, Cfg.Logger.Color(), dbc.IgnoreNotFound, logWrite, Cfg.DBCfg.DryRun); err != nil {
+				return err
 			}
-			ignoreNotFound := dbc.IgnoreNotFound
-			if !ignoreNotFound && Cfg.DBCfg.IgnoreNotFound {
-				ignoreNotFound = Cfg.DBCfg.IgnoreNotFound
-			}
-			initDb(driver, dbc.DSN, prefix, key, logMode, slow, maxIdle, maxOpen, maxLifetime, singular, Cfg.Logger.Color(), ignoreNotFound, logWrite, Cfg.DBCfg.DryRun)
+			initReplicas(key, dbc.Replicas, dbc.Driver, dbc.Prefix, logMode, dbc.SlowThreshold, dbc.MaxIdleConn, dbc.MaxOpenConn, dbc.MaxLifetime,
+				Cfg.DBCfg.Singular, Cfg.Logger.Color(), dbc.IgnoreNotFound, logWrite, Cfg.DBCfg.DryRun)
 		}
 	}
+	return nil
+}
+
+// mergeDbCfg 未配置的项使用全局 DBCfg 的配置
+func mergeDbCfg(dbc config.DB) config.DB {
+	dc := GetCfg().DBCfg
+	if dbc.LogMode == "" {
+		dbc.LogMode = dc.LogMode
+	}
+	if dbc.Prefix == "" {
+		dbc.Prefix = dc.Prefix
+	}
+	if dbc.SlowThreshold < 1 && dc.SlowThreshold > 0 {
+		dbc.SlowThreshold = dc.SlowThreshold
+	}
+	if dbc.MaxIdleConn < 1 {
+		dbc.MaxIdleConn = dc.GetMaxIdleConn()
+	}
+	if dbc.MaxOpenConn < 1 {
+		dbc.MaxOpenConn = dc.GetMaxOpenConn()
+	}
+	if dbc.MaxLifetime < 1 {
+		dbc.MaxLifetime = dc.GetMaxLifetime()
+	}
+	if dbc.Driver == "" {
+		dbc.Driver = dc.Driver
+	}
+	if !dbc.IgnoreNotFound {
+		dbc.IgnoreNotFound = dc.IgnoreNotFound
+	}
+	return dbc
+}
 
+func initDb(driver, dns, prefix, key string, logMode logger.LogLevel, slow, maxIdle, maxOpen, maxLifetime int, singular, color, ignoreNotFound bool, logWrite io.Writer, dryRun bool) error {
+	db, err := openDb(driver, dns, prefix, logMode, slow, maxIdle, maxOpen, maxLifetime, singular, color, ignoreNotFound, logWrite, dryRun)
+	if err != nil {
+		slog.Error("connect db err ", "dns", dns, "key", key, "err", err)
+		return errors.Wrapf(err, "connect db %s", key)
+	}
+	SetDb(key, db)
+	return nil
 }
 
-func initDb(driver, dns, prefix, key string, logMode logger.LogLevel, slow, maxIdle, maxOpen, maxLifetime int, singular, color, ignoreNotFound bool, logWrite io.Writer, dryRun bool) {
+// openDb 打开数据库连接并设置连接池
+func openDb(driver, dns, prefix string, logMode logger.LogLevel, slow, maxIdle, maxOpen, maxLifetime int, singular, color, ignoreNotFound bool, logWrite io.Writer, dryRun bool) (*gorm.DB, error) {
 	var db *gorm.DB
 	var err error
 	switch driver {
@@ -86,19 +105,17 @@ func initDb(driver, dns, prefix, key string, logMode logger.LogLevel, slow, maxI
 		err = errors.New("db err")
 	}
 	if err != nil {
-		slog.Error("connect db err ", "dns", dns, "key", key, "err", err)
-		panic(err)
+		return nil, err
 	}
 	var sqlDB *sql.DB
 	sqlDB, err = db.DB()
 	if err != nil {
-		slog.Error("connect db err ", "dns", dns, "key", key, "err", err)
-		panic(err)
+		return nil, err
 	}
 	sqlDB.SetMaxIdleConns(maxIdle)
 	sqlDB.SetMaxOpenConns(maxOpen)
 	sqlDB.SetConnMaxLifetime(time.Minute * time.Duration(maxLifetime))
-	SetDb(key, db)
+	return db, nil
 }
 
 func GetGromLogCfg(logMode logger.LogLevel, prefix string, slowThreshold int, singular, color, ignoreNotFound bool, logW io.Writer, dryRun bool) *gorm.Config {
@@ -126,28 +143,41 @@ func GetGromLogCfg(logMode logger.LogLevel, prefix string, slowThreshold int, si
 }
 
 func SetDb(key string, db *gorm.DB) {
+	registerCallbacks(db)
 	lock.Lock()
 	defer lock.Unlock()
 	dbs[key] = db
 }
 
-// GetDb 获取所有map里的db数据
+// Dbs 获取所有db的副本
 func Dbs() map[string]*gorm.DB {
-	// lock.RLock()
-	// defer lock.RUnlock()
-	return dbs
+	lock.RLock()
+	defer lock.RUnlock()
+	all := make(map[string]*gorm.DB, len(dbs))
+	for k, v := range dbs {
+		all[k] = v
+	}
+	return all
 }
 
-func Db(name string) *gorm.DB {
+// GetDb 获取db，不存在时返回 ErrDbNotFound
+func GetDb(name string) (*gorm.DB, error) {
 	lock.RLock()
 	defer lock.RUnlock()
-	dbHub := dbs
-	if db, ok := dbHub[name]; !ok || db == nil {
-		slog.Error("db init err", "err", errors.New(name))
+	if db, ok := dbs[name]; ok && db != nil {
+		return db, nil
+	}
+	return nil, errors.Wrap(ErrDbNotFound, name)
+}
+
+// Db 获取db，不存在时 panic，运行时动态的db请使用 GetDb
+func Db(name string) *gorm.DB {
+	db, err := GetDb(name)
+	if err != nil {
+		slog.Error("db init err", "err", err)
 		panic("db not init")
-	} else {
-		return db
 	}
+	return db
 }
 
 // 获取默认的（master）db
@@ -0,0 +1,293 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/common/response"
+)
+
+// SqlRecord 一次sql执行的记录
+type SqlRecord struct {
+	Fingerprint string        //去除参数后的sql
+	SQL         string        //完整sql
+	Elapsed     time.Duration //耗时
+	Rows        int64         //影响行数 -1 为未知
+	Err         error         //执行错误（不含记录不存在）
+	Slow        bool          //超过慢查询阈值
+}
+
+// DbMetrics 数据库指标收集，可接入 Prometheus 等
+type DbMetrics interface {
+	// ObserveSql 每次sql执行后调用，需并发安全且尽量快
+	ObserveSql(r SqlRecord)
+}
+
+var (
+	dbMetrics     []DbMetrics
+	dbMetricsLock sync.RWMutex
+)
+
+// AddDbMetrics 添加指标收集器
+func AddDbMetrics(m DbMetrics) {
+	dbMetricsLock.Lock()
+	defer dbMetricsLock.Unlock()
+	dbMetrics = append(dbMetrics, m)
+}
+
+// removeDbMetrics 移除 match 返回 true 的收集器
+func removeDbMetrics(match func(m DbMetrics) bool) {
+	dbMetricsLock.Lock()
+	defer dbMetricsLock.Unlock()
+	ms := dbMetrics[:0:0]
+	for _, m := range dbMetrics {
+		if !match(m) {
+			ms = append(ms, m)
+		}
+	}
+	dbMetrics = ms
+}
+
+func hasDbMetrics() bool {
+	dbMetricsLock.RLock()
+	defer dbMetricsLock.RUnlock()
+	return len(dbMetrics) > 0
+}
+
+func observeSql(r SqlRecord) {
+	dbMetricsLock.RLock()
+	ms := dbMetrics
+	dbMetricsLock.RUnlock()
+	for _, m := range ms {
+		m.ObserveSql(r)
+	}
+}
+
+var (
+	fpStringRe = regexp.MustCompile(`'(?:[^']|'')*'`)
+	fpNumberRe = regexp.MustCompile(`-?\b\d+(?:\.\d+)?\b`)
+	fpInRe     = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+	fpSpaceRe  = regexp.MustCompile(`\s+`)
+)
+
+// SqlFingerprint 将sql中的字面量替换为 ?，in 列表合并为 (?...)
+func SqlFingerprint(sql string) string {
+	sql = fpStringRe.ReplaceAllString(sql, "?")
+	sql = fpNumberRe.ReplaceAllString(sql, "?")
+	sql = fpInRe.ReplaceAllString(sql, "(?...)")
+	return strings.TrimSpace(fpSpaceRe.ReplaceAllString(sql, " "))
+}
+
+// SqlBuckets 耗时直方图的上界（毫秒），最后一个桶为 +Inf
+var SqlBuckets = []float64{1, 5, 10, 50, 100, 500, 1000, 5000}
+
+// SqlStat 单条sql指纹的统计
+type SqlStat struct {
+	Fingerprint string    `json:"fingerprint"`
+	Count       int64     `json:"count"`
+	Errors      int64     `json:"errors"`
+	Slow        int64     `json:"slow"`
+	TotalMs     float64   `json:"totalMs"`
+	MaxMs       float64   `json:"maxMs"`
+	Buckets     []int64   `json:"buckets"` //与 SqlBuckets 对应的累计次数，最后一个为 +Inf
+	LastAt      time.Time `json:"lastAt"`
+}
+
+// SlowSql 慢sql，只保留指纹，避免通过统计接口泄露参数
+type SlowSql struct {
+	Fingerprint string    `json:"fingerprint"`
+	Ms          float64   `json:"ms"`
+	Rows        int64     `json:"rows"`
+	At          time.Time `json:"at"`
+}
+
+// maxSqlFingerprints 统计的指纹数上限，超出时淘汰最久未执行的指纹
+const maxSqlFingerprints = 1000
+
+// SqlStats 内置的sql统计，按指纹聚合并保留最慢的 top 条
+type SqlStats struct {
+	mu    sync.Mutex
+	top   int
+	max   int
+	stats map[string]*SqlStat
+	slow  []SlowSql
+}
+
+func NewSqlStats(top int) *SqlStats {
+	return &SqlStats{top: top, max: maxSqlFingerprints, stats: make(map[string]*SqlStat)}
+}
+
+func (s *SqlStats) ObserveSql(r SqlRecord) {
+	ms := float64(r.Elapsed.Nanoseconds()) / 1e6
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stats[r.Fingerprint]
+	if !ok {
+		if len(s.stats) >= s.max {
+			s.evict()
+		}
+		st = &SqlStat{Fingerprint: r.Fingerprint, Buckets: make([]int64, len(SqlBuckets)+1)}
+		s.stats[r.Fingerprint] = st
+	}
+	st.Count++
+	st.TotalMs += ms
+	st.LastAt = now
+	if ms > st.MaxMs {
+		st.MaxMs = ms
+	}
+	if r.Err != nil {
+		st.Errors++
+	}
+	for i, b := range SqlBuckets {
+		if ms <= b {
+			st.Buckets[i]++
+		}
+	}
+	st.Buckets[len(SqlBuckets)]++
+	if !r.Slow {
+		return
+	}
+	st.Slow++
+	if len(s.slow) >= s.top && s.top > 0 && ms <= s.slow[len(s.slow)-1].Ms {
+		return
+	}
+	s.slow = append(s.slow, SlowSql{Fingerprint: r.Fingerprint, Ms: ms, Rows: r.Rows, At: now})
+	sort.SliceStable(s.slow, func(i, j int) bool {
+		return s.slow[i].Ms > s.slow[j].Ms
+	})
+	if s.top > 0 && len(s.slow) > s.top {
+		s.slow = s.slow[:s.top]
+	}
+}
+
+// evict 淘汰最久未执行的指纹
+func (s *SqlStats) evict() {
+	var (
+		oldest string
+		at     time.Time
+	)
+	for fp, st := range s.stats {
+		if oldest == "" || st.LastAt.Before(at) {
+			oldest, at = fp, st.LastAt
+		}
+	}
+	delete(s.stats, oldest)
+}
+
+// Stats 按执行次数倒序返回各指纹的统计
+func (s *SqlStats) Stats() []SqlStat {
+	s.mu.Lock()
+	res := make([]SqlStat, 0, len(s.stats))
+	for _, st := range s.stats {
+		c := *st
+		c.Buckets = append([]int64(nil), st.Buckets...)
+		res = append(res, c)
+	}
+	s.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count == res[j].Count {
+			return res[i].Fingerprint < res[j].Fingerprint
+		}
+		return res[i].Count > res[j].Count
+	})
+	return res
+}
+
+// SlowTop 最慢的sql，按耗时倒序
+func (s *SqlStats) SlowTop() []SlowSql {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SlowSql(nil), s.slow...)
+}
+
+// Reset 清空统计
+func (s *SqlStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = make(map[string]*SqlStat)
+	s.slow = nil
+}
+
+var (
+	sqlStats     *SqlStats
+	sqlStatsOnce sync.Once
+)
+
+// GetSqlStats 内置sql统计，DBCfg.Metrics 开启时在 dbInit 中注册
+func GetSqlStats() *SqlStats {
+	sqlStatsOnce.Do(func() {
+		sqlStats = NewSqlStats(Cfg.DBCfg.GetSlowTop())
+	})
+	return sqlStats
+}
+
+// DbPoolStats 所有db（含只读副本）的连接池状态，副本的键为 {key}:replica{序号}
+func DbPoolStats() map[string]sql.DBStats {
+	res := make(map[string]sql.DBStats)
+	for key, db := range Dbs() {
+		if sqlDB, err := db.DB(); err == nil {
+			res[key] = sqlDB.Stats()
+		}
+	}
+	replicaLock.RLock()
+	defer replicaLock.RUnlock()
+	for key, nodes := range replicas {
+		for i, n := range nodes {
+			if sqlDB, err := n.db.DB(); err == nil {
+				res[fmt.Sprintf("%s:replica%d", key, i)] = sqlDB.Stats()
+			}
+		}
+	}
+	return res
+}
+
+// DbPool 连接池状态，时长为毫秒
+type DbPool struct {
+	MaxOpen           int   `json:"maxOpen"`
+	Open              int   `json:"open"`
+	InUse             int   `json:"inUse"`
+	Idle              int   `json:"idle"`
+	WaitCount         int64 `json:"waitCount"`
+	WaitMs            int64 `json:"waitMs"`
+	MaxIdleClosed     int64 `json:"maxIdleClosed"`
+	MaxIdleTimeClosed int64 `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed int64 `json:"maxLifetimeClosed"`
+}
+
+// DbStatsHandler 数据库统计的 JSON 接口，需自行挂载并做好鉴权
+// e.g. r.GET("/admin/db/stats", core.DbStatsHandler)
+func DbStatsHandler(c *gin.Context) {
+	pools := make(map[string]DbPool)
+	for key, st := range DbPoolStats() {
+		pools[key] = DbPool{
+			MaxOpen:           st.MaxOpenConnections,
+			Open:              st.OpenConnections,
+			InUse:             st.InUse,
+			Idle:              st.Idle,
+			WaitCount:         st.WaitCount,
+			WaitMs:            st.WaitDuration.Milliseconds(),
+			MaxIdleClosed:     st.MaxIdleClosed,
+			MaxIdleTimeClosed: st.MaxIdleTimeClosed,
+			MaxLifetimeClosed: st.MaxLifetimeClosed,
+		}
+	}
+	enable := GetCfg().DBCfg.Metrics
+	data := gin.H{
+		"pools":   pools,
+		"buckets": SqlBuckets,
+		"enable":  enable,
+	}
+	if enable {
+		stats := GetSqlStats()
+		data["statements"] = stats.Stats()
+		data["slow"] = stats.SlowTop()
+	}
+	response.OK(c, data, "")
+}
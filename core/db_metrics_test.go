@@ -0,0 +1,140 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/config"
+)
+
+func TestSqlFingerprint(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM `user` WHERE id = 12 AND name = 'a''b'":    "SELECT * FROM `user` WHERE id = ? AND name = ?",
+		"select * from t1 where id in (1, 2,3)\n  limit 10":       "select * from t1 where id in (?...) limit ?",
+		"UPDATE `t` SET `score`=-1.5 WHERE `id` = 3":              "UPDATE `t` SET `score`=? WHERE `id` = ?",
+		"INSERT INTO `t` (`name`) VALUES ('x'),('y') RETURNING 1": "INSERT INTO `t` (`name`) VALUES (?),(?) RETURNING ?",
+	}
+	for sql, want := range cases {
+		if got := SqlFingerprint(sql); got != want {
+			t.Errorf("SqlFingerprint(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
+
+func TestSqlStats(t *testing.T) {
+	s := NewSqlStats(2)
+	for i, ms := range []int{3, 30, 300, 3000} {
+		s.ObserveSql(SqlRecord{Fingerprint: "q", SQL: "q", Elapsed: time.Duration(ms) * time.Millisecond, Slow: i > 0})
+	}
+	s.ObserveSql(SqlRecord{Fingerprint: "e", Err: ErrRecordNotFound})
+	stats := s.Stats()
+	if len(stats) != 2 || stats[0].Fingerprint != "q" {
+		t.Fatalf("stats %+v", stats)
+	}
+	q := stats[0]
+	if q.Count != 4 || q.Slow != 3 || q.MaxMs != 3000 {
+		t.Errorf("stat %+v", q)
+	}
+	// 累计桶: <=5ms 1次, <=50ms 2次, <=500ms 3次, <=5000ms 4次
+	if q.Buckets[1] != 1 || q.Buckets[3] != 2 || q.Buckets[5] != 3 || q.Buckets[7] != 4 || q.Buckets[8] != 4 {
+		t.Errorf("buckets %v", q.Buckets)
+	}
+	if stats[1].Errors != 1 {
+		t.Errorf("errors %+v", stats[1])
+	}
+	slow := s.SlowTop()
+	if len(slow) != 2 || slow[0].Ms != 3000 || slow[1].Ms != 300 {
+		t.Errorf("slow top %+v", slow)
+	}
+}
+
+func TestSqlStatsLimit(t *testing.T) {
+	s := NewSqlStats(10)
+	s.max = 2
+	s.ObserveSql(SqlRecord{Fingerprint: "a"})
+	s.ObserveSql(SqlRecord{Fingerprint: "b"})
+	s.ObserveSql(SqlRecord{Fingerprint: "a"})
+	s.ObserveSql(SqlRecord{Fingerprint: "c", SQL: "select 'secret'", Slow: true})
+	stats := s.Stats()
+	if len(stats) != 2 || stats[0].Fingerprint != "a" || stats[1].Fingerprint != "c" {
+		t.Errorf("least recently used fingerprint should be evicted %+v", stats)
+	}
+	b, _ := json.Marshal(s.SlowTop())
+	if strings.Contains(string(b), "secret") {
+		t.Errorf("slow sql should not keep the full sql %s", b)
+	}
+}
+
+func TestDbMetrics(t *testing.T) {
+	Cfg.DBCfg.Driver = Sqlite.String()
+	Cfg.DBCfg.LogMode = "silent"
+	Cfg.DBCfg.Metrics = true
+	defer func() {
+		Cfg.DBCfg.Driver = ""
+		Cfg.DBCfg.LogMode = ""
+		Cfg.DBCfg.Metrics = false
+	}()
+	stats := NewSqlStats(10)
+	AddDbMetrics(stats)
+	defer func() {
+		dbMetricsLock.Lock()
+		dbMetrics = nil
+		dbMetricsLock.Unlock()
+	}()
+	AddDbMetrics(GetSqlStats())
+
+	if err := AddDb("metrics", config.DB{DSN: "file:metrics?mode=memory&cache=shared"}); err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDb("metrics")
+	db := Db("metrics")
+	db.Exec("create table m (id int)")
+	db.Exec("select * from m where id = ?", 1)
+	db.Exec("select * from m where id = ?", 2)
+	db.Exec("select * from missing")
+
+	found := false
+	for _, st := range stats.Stats() {
+		switch st.Fingerprint {
+		case "select * from m where id = ?":
+			found = true
+			if st.Count != 2 {
+				t.Errorf("count %d", st.Count)
+			}
+		case "select * from missing":
+			if st.Errors != 1 {
+				t.Errorf("errors %d", st.Errors)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("fingerprint not collected %+v", stats.Stats())
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/admin/db/stats", nil)
+	DbStatsHandler(c)
+	var res struct {
+		Code int `json:"code"`
+		Data struct {
+			Pools      map[string]DbPool `json:"pools"`
+			Statements []SqlStat         `json:"statements"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res.Data.Pools["metrics"]; !ok || res.Code != 1 {
+		t.Errorf("pool stats missing %s", w.Body.String())
+	}
+	if len(res.Data.Statements) == 0 {
+		t.Errorf("statements missing %s", w.Body.String())
+	}
+}
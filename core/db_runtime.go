@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core/ebus"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDbNotFound = errors.New("db not found")
+	ErrDbExists   = errors.New("db already exists")
+)
+
+var (
+	// dbDrainTimeout 关闭旧连接池前等待使用中连接归还的最长时间
+	dbDrainTimeout = 30 * time.Second
+	dbDrainTick    = 100 * time.Millisecond
+)
+
+// OpenDb 按配置打开db（含只读副本）但不注册，未配置的项使用全局 DBCfg
+func OpenDb(dbc config.DB) (*gorm.DB, error) {
+	db, _, err := openDbCfg("", dbc)
+	return db, err
+}
+
+func openDbCfg(key string, dbc config.DB) (*gorm.DB, []*replicaNode, error) {
+	if dbc.DSN == "" {
+		return nil, nil, errors.Errorf("db %s dsn is empty", key)
+	}
+	dbc = mergeDbCfg(dbc)
+	cfg := GetCfg()
+	logMode := config.GetLogMode(dbc.LogMode)
+	db, err := openDb(dbc.Driver, dbc.DSN, dbc.Prefix, logMode, dbc.SlowThreshold, dbc.MaxIdleConn, dbc.MaxOpenConn, dbc.MaxLifetime,
+		cfg.DBCfg.Singular, cfg.Logger.Color(), dbc.IgnoreNotFound, dbLogWrite, cfg.DBCfg.DryRun)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "connect db %s", key)
+	}
+	nodes := openReplicas(key, dbc.Replicas, dbc.Driver, dbc.Prefix, logMode, dbc.SlowThreshold, dbc.MaxIdleConn, dbc.MaxOpenConn, dbc.MaxLifetime,
+		cfg.DBCfg.Singular, cfg.Logger.Color(), dbc.IgnoreNotFound, dbLogWrite, cfg.DBCfg.DryRun)
+	return db, nodes, nil
+}
+
+// AddDb 运行时按配置新增db，已存在时返回 ErrDbExists
+func AddDb(key string, dbc config.DB) error {
+	if _, err := GetDb(key); err == nil {
+		return errors.Wrap(ErrDbExists, key)
+	}
+	db, nodes, err := openDbCfg(key, dbc)
+	if err != nil {
+		return err
+	}
+	registerCallbacks(db)
+	lock.Lock()
+	if _, ok := dbs[key]; ok {
+		lock.Unlock()
+		_ = closeDb(context.Background(), key, db)
+		closeReplicas(context.Background(), key, nodes)
+		return errors.Wrap(ErrDbExists, key)
+	}
+	dbs[key] = db
+	lock.Unlock()
+	setReplicas(key, nodes...)
+	slog.Info("db added", "key", key)
+	publish(ebus.DbAdded{Key: key})
+	return nil
+}
+
+// ReloadDb 按新配置重建db，新连接可用后替换，旧连接池（含只读副本）排空后关闭
+// 新连接失败时保留旧连接并返回错误
+func ReloadDb(key string, dbc config.DB) error {
+	db, nodes, err := openDbCfg(key, dbc)
+	if err != nil {
+		return err
+	}
+	if err = ReplaceDb(key, db); err != nil {
+		closeReplicas(context.Background(), key, nodes)
+		return err
+	}
+	closeReplicas(context.Background(), key, setReplicas(key, nodes...))
+	return nil
+}
+
+// ReplaceDb 替换db，旧连接池排空后关闭，key 不存在时等同于 SetDb
+func ReplaceDb(key string, db *gorm.DB) error {
+	if db == nil {
+		return errors.Errorf("db %s is nil", key)
+	}
+	registerCallbacks(db)
+	lock.Lock()
+	old := dbs[key]
+	dbs[key] = db
+	lock.Unlock()
+	slog.Info("db replaced", "key", key)
+	publish(ebus.DbReplaced{Key: key})
+	if old == nil || old == db {
+		return nil
+	}
+	return closeDb(context.Background(), key, old)
+}
+
+// RemoveDb 移除db及其只读副本，连接池排空后关闭，不存在时返回 ErrDbNotFound
+func RemoveDb(key string) error {
+	return removeDb(context.Background(), key)
+}
+
+// removeDb ctx 结束时不再等待排空，直接关闭连接池
+func removeDb(ctx context.Context, key string) error {
+	lock.Lock()
+	old, ok := dbs[key]
+	delete(dbs, key)
+	lock.Unlock()
+	if !ok {
+		return errors.Wrap(ErrDbNotFound, key)
+	}
+	tenantLock.Lock()
+	delete(tenantPools, key)
+	tenantLock.Unlock()
+	closeReplicas(ctx, key, setReplicas(key))
+	slog.Info("db removed", "key", key)
+	publish(ebus.DbRemoved{Key: key})
+	if old == nil {
+		return nil
+	}
+	return closeDb(ctx, key, old)
+}
+
+// closeDb 等待使用中的连接归还后关闭连接池，最长等待 dbDrainTimeout 或到 ctx 结束
+func closeDb(ctx context.Context, key string, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, dbDrainTimeout)
+	defer cancel()
+	ticker := time.NewTicker(dbDrainTick)
+	defer ticker.Stop()
+	for sqlDB.Stats().InUse > 0 && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+	if n := sqlDB.Stats().InUse; n > 0 {
+		slog.Warn("db drain timeout", "key", key, "inUse", n)
+	}
+	if err = sqlDB.Close(); err != nil {
+		slog.Error("close db err", "key", key, "err", err)
+		return errors.Wrapf(err, "close db %s", key)
+	}
+	return nil
+}
+
+func closeReplicas(ctx context.Context, key string, nodes []*replicaNode) {
+	for _, n := range nodes {
+		_ = closeDb(ctx, key, n.db)
+	}
+}
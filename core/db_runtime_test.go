@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/pkg/errors"
+)
+
+func TestDbRuntime(t *testing.T) {
+	Cfg.DBCfg.Driver = Sqlite.String()
+	Cfg.DBCfg.LogMode = "silent"
+	drain := dbDrainTimeout
+	dbDrainTimeout = 200 * time.Millisecond
+	defer func() {
+		Cfg.DBCfg.Driver = ""
+		Cfg.DBCfg.LogMode = ""
+		dbDrainTimeout = drain
+	}()
+
+	if _, err := GetDb("hot"); !errors.Is(err, ErrDbNotFound) {
+		t.Fatalf("missing db err %v", err)
+	}
+	dbc := config.DB{DSN: "file:hot?mode=memory&cache=shared"}
+	if err := AddDb("hot", dbc); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddDb("hot", dbc); !errors.Is(err, ErrDbExists) {
+		t.Errorf("duplicate add err %v", err)
+	}
+	old, err := GetDb("hot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = old.Exec("create table t (id int)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 重载时使用中的连接排空后关闭
+	conn, err := old.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := conn.Query("select 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		rows.Close()
+	}()
+	dbc.DSN = "file:hot2?mode=memory&cache=shared"
+	if err = ReloadDb("hot", dbc); err != nil {
+		t.Fatal(err)
+	}
+	if conn.Stats().InUse != 0 {
+		t.Error("old pool should be drained")
+	}
+	if err = conn.Ping(); err == nil {
+		t.Error("old pool should be closed")
+	}
+	if db, _ := GetDb("hot"); db == old {
+		t.Error("db should be replaced")
+	}
+
+	if err = ReloadDb("hot", config.DB{}); err == nil {
+		t.Error("reload with empty dsn should fail")
+	}
+	if _, err = GetDb("hot"); err != nil {
+		t.Error("failed reload should keep old db")
+	}
+
+	if err = RemoveDb("hot"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Dbs()["hot"]; ok {
+		t.Error("db should be removed")
+	}
+	if err = RemoveDb("hot"); !errors.Is(err, ErrDbNotFound) {
+		t.Errorf("remove missing db err %v", err)
+	}
+}
+
+func TestStopDbBounded(t *testing.T) {
+	Cfg.DBCfg.Driver = Sqlite.String()
+	Cfg.DBCfg.LogMode = "silent"
+	defer func() {
+		Cfg.DBCfg.Driver = ""
+		Cfg.DBCfg.LogMode = ""
+	}()
+	var conns []interface{ Ping() error }
+	for _, key := range []string{"busy1", "busy2"} {
+		if err := AddDb(key, config.DB{DSN: "file:" + key + "?mode=memory&cache=shared"}); err != nil {
+			t.Fatal(err)
+		}
+		conn, _ := Db(key).DB()
+		rows, err := conn.Query("select 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		conns = append(conns, conn)
+	}
+
+	// 使用中的连接不归还时，按 ctx 截止时间并发关闭，不等待 dbDrainTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	if err := stopDb(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(begin); d > time.Second {
+		t.Fatalf("stop db took %s", d)
+	}
+	if len(Dbs()) != 0 {
+		t.Fatal("dbs should be removed")
+	}
+	for _, conn := range conns {
+		if conn.Ping() == nil {
+			t.Error("pool should be closed")
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// Package ebus 应用事件总线
+//
+// Bus 为类型化事件总线，按事件类型分发，支持同步与异步处理函数，core 的生命周期事件均通过 Default 发布。
+// EventBus 为基于 x/eventbus 的字符串主题总线，仅为兼容旧的订阅方式保留，不再新增主题；
+// core 发布类型化事件时同时发布对应的旧主题，两者互不依赖，新代码请使用 Subscribe
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// Default 默认的类型化事件总线，Subscribe、Publish 使用
+var Default = NewBus()
+
+// PanicError 处理函数 panic 时的错误
+type PanicError struct {
+	Event string
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("ebus: %s handler panic: %v", e.Event, e.Value)
+}
+
+var (
+	// ErrClosed 总线关闭后发布异步事件
+	ErrClosed = errors.New("ebus: closed")
+	// ErrQueueFull 异步处理函数中发布异步事件时队列已满，不等待，避免所有协程互相等待
+	ErrQueueFull = errors.New("ebus: queue full")
+)
+
+type BusOption func(*Bus)
+
+// WithWorkers 异步处理的协程数，默认 8
+func WithWorkers(n int) BusOption {
+	return func(b *Bus) {
+		if n > 0 {
+			b.workers = n
+		}
+	}
+}
+
+// WithQueueSize 异步队列长度，队列满时 Publish 等待直到 ctx 结束，异步处理函数中发布时返回 ErrQueueFull，默认 1024
+func WithQueueSize(n int) BusOption {
+	return func(b *Bus) {
+		if n > 0 {
+			b.queueSize = n
+		}
+	}
+}
+
+// WithErrorHandler 异步处理函数的错误回调，默认输出日志
+func WithErrorHandler(fn func(event string, err error)) BusOption {
+	return func(b *Bus) {
+		b.onError = fn
+	}
+}
+
+// Bus 类型化事件总线，按事件类型分发
+// 同步处理函数在 Publish 中按订阅顺序执行并返回错误，异步处理函数由固定数量的协程执行
+// 处理函数的 panic 被恢复为 PanicError，不影响其他处理函数
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[reflect.Type][]*handler
+	nextId   uint64
+
+	workers   int
+	queueSize int
+	onError   func(event string, err error)
+	queue     chan task
+
+	// inflight 正在入队或未处理完的异步事件数，归零时关闭 idle，Wait 等待 idle 而不使用 WaitGroup，入队与等待可以并发
+	// 关闭后 inflight 归零时关闭队列，协程退出，此时不再有发送方
+	stateMu  sync.Mutex
+	started  bool
+	closed   bool
+	inflight int
+	idle     chan struct{}
+}
+
+type handler struct {
+	id    uint64
+	async bool
+	once  bool
+	fired atomic.Bool
+	call  func(ctx context.Context, event any) error
+}
+
+// workerKey 异步处理函数的 ctx 中的值，标记由哪个总线的协程执行
+type workerKey struct{}
+
+type task struct {
+	ctx   context.Context
+	name  string
+	h     *handler
+	event any
+}
+
+func NewBus(opts ...BusOption) *Bus {
+	b := &Bus{
+		handlers:  make(map[reflect.Type][]*handler),
+		workers:   8,
+		queueSize: 1024,
+		onError: func(event string, err error) {
+			slog.Error("ebus handler err", "event", event, "err", err)
+		},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+type SubscribeOption func(*handler)
+
+// Async 异步处理，Publish 不等待也不返回其错误
+func Async() SubscribeOption {
+	return func(h *handler) {
+		h.async = true
+	}
+}
+
+// Once 只处理一次
+func Once() SubscribeOption {
+	return func(h *handler) {
+		h.once = true
+	}
+}
+
+// Subscribe 在默认总线上订阅 T 类型的事件，返回取消订阅的函数
+func Subscribe[T any](fn func(ctx context.Context, event T) error, opts ...SubscribeOption) (unsubscribe func()) {
+	return SubscribeTo(Default, fn, opts...)
+}
+
+// Publish 在默认总线上发布事件，返回同步处理函数的错误
+func Publish[T any](ctx context.Context, event T) error {
+	return PublishTo(Default, ctx, event)
+}
+
+// SubscribeTo 在指定总线上订阅 T 类型的事件
+func SubscribeTo[T any](b *Bus, fn func(ctx context.Context, event T) error, opts ...SubscribeOption) (unsubscribe func()) {
+	h := &handler{call: func(ctx context.Context, event any) error {
+		return fn(ctx, event.(T))
+	}}
+	for _, opt := range opts {
+		opt(h)
+	}
+	typ := reflect.TypeFor[T]()
+	b.mu.Lock()
+	b.nextId++
+	h.id = b.nextId
+	b.handlers[typ] = append(b.handlers[typ], h)
+	b.mu.Unlock()
+	return func() {
+		b.remove(typ, h.id)
+	}
+}
+
+// PublishTo 在指定总线上发布事件
+func PublishTo[T any](b *Bus, ctx context.Context, event T) error {
+	typ := reflect.TypeFor[T]()
+	name := typ.String()
+	b.mu.RLock()
+	handlers := append([]*handler(nil), b.handlers[typ]...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if h.once {
+			if !h.fired.CompareAndSwap(false, true) {
+				continue
+			}
+			b.remove(typ, h.id)
+		}
+		if !h.async {
+			if err := call(ctx, name, h, event); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err := b.enqueue(task{ctx: ctx, name: name, h: h, event: event}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HasSubscriber T 类型的事件是否有订阅
+func HasSubscriber[T any](b *Bus) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.handlers[reflect.TypeFor[T]()]) > 0
+}
+
+func (b *Bus) remove(typ reflect.Type, id uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	hs := b.handlers[typ]
+	for i, h := range hs {
+		if h.id == id {
+			b.handlers[typ] = append(hs[:i:i], hs[i+1:]...)
+			break
+		}
+	}
+	if len(b.handlers[typ]) == 0 {
+		delete(b.handlers, typ)
+	}
+}
+
+func (b *Bus) enqueue(t task) error {
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	// 协程中等待队列可能所有协程都在等待，不再有协程处理队列
+	if w, _ := t.ctx.Value(workerKey{}).(*Bus); w == b {
+		select {
+		case b.queue <- t:
+			return nil
+		default:
+			b.release()
+			return fmt.Errorf("ebus: %s: %w", t.name, ErrQueueFull)
+		}
+	}
+	select {
+	case b.queue <- t:
+		return nil
+	case <-t.ctx.Done():
+		b.release()
+		return fmt.Errorf("ebus: %s queue full: %w", t.name, t.ctx.Err())
+	}
+}
+
+// acquire 检查是否关闭并计入 inflight，首次调用时启动协程
+func (b *Bus) acquire() error {
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	if !b.started {
+		b.start()
+		b.started = true
+	}
+	if b.inflight == 0 {
+		b.idle = make(chan struct{})
+	}
+	b.inflight++
+	return nil
+}
+
+// release 处理完成或入队失败，关闭后最后一个事件处理完时关闭队列
+func (b *Bus) release() {
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	b.inflight--
+	if b.inflight > 0 {
+		return
+	}
+	close(b.idle)
+	if b.closed {
+		close(b.queue)
+	}
+}
+
+func (b *Bus) start() {
+	b.queue = make(chan task, b.queueSize)
+	for i := 0; i < b.workers; i++ {
+		go func() {
+			for t := range b.queue {
+				// 发布方的 ctx 结束不取消异步处理，保留其中的值
+				ctx := context.WithValue(context.WithoutCancel(t.ctx), workerKey{}, b)
+				if err := call(ctx, t.name, t.h, t.event); err != nil && b.onError != nil {
+					b.onError(t.name, err)
+				}
+				b.release()
+			}
+		}()
+	}
+}
+
+// Wait 等待已发布的异步事件处理完成，ctx 结束时返回其错误
+func (b *Bus) Wait(ctx context.Context) error {
+	b.stateMu.Lock()
+	if b.inflight == 0 {
+		b.stateMu.Unlock()
+		return nil
+	}
+	idle := b.idle
+	b.stateMu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 不再接收异步事件，等待队列中的事件处理完成，之后协程退出
+// ctx 结束时返回其错误，协程仍会处理完剩余的事件后退出
+func (b *Bus) Close(ctx context.Context) error {
+	b.stateMu.Lock()
+	if b.closed {
+		b.stateMu.Unlock()
+		return nil
+	}
+	b.closed = true
+	if b.started && b.inflight == 0 {
+		close(b.queue)
+	}
+	b.stateMu.Unlock()
+	return b.Wait(ctx)
+}
+
+func call(ctx context.Context, name string, h *handler, event any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Event: name, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return h.call(ctx, event)
+}
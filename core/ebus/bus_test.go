@@ -0,0 +1,205 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type userCreated struct {
+	Id int
+}
+
+func TestPublishSync(t *testing.T) {
+	b := NewBus()
+	var got []string
+	SubscribeTo(b, func(_ context.Context, e userCreated) error {
+		got = append(got, "a")
+		return errors.New("a failed")
+	})
+	SubscribeTo(b, func(_ context.Context, e userCreated) error {
+		panic("boom")
+	})
+	unsubscribe := SubscribeTo(b, func(_ context.Context, e userCreated) error {
+		got = append(got, "c")
+		// 处理函数中可以继续发布事件
+		return PublishTo(b, context.Background(), DbAdded{Key: "x"})
+	})
+	SubscribeTo(b, func(_ context.Context, e userCreated) error {
+		got = append(got, "once")
+		return nil
+	}, Once())
+	SubscribeTo(b, func(_ context.Context, e DbAdded) error {
+		got = append(got, "db "+e.Key)
+		return nil
+	})
+
+	err := PublishTo(b, context.Background(), userCreated{Id: 1})
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" || pe.Event != "ebus.userCreated" {
+		t.Fatalf("err = %v", err)
+	}
+	if err.Error() != "a failed\nebus: ebus.userCreated handler panic: boom" {
+		t.Fatalf("err = %q", err)
+	}
+	want := []string{"a", "c", "db x", "once"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	got = nil
+	unsubscribe()
+	_ = PublishTo(b, context.Background(), userCreated{Id: 2})
+	if len(got) != 1 || got[0] != "a" {
+		t.Fatalf("after unsubscribe got %v", got)
+	}
+	if !HasSubscriber[userCreated](b) || HasSubscriber[AppStarted](b) {
+		t.Fatal("HasSubscriber")
+	}
+}
+
+func TestPublishAsync(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	b := NewBus(WithWorkers(2), WithQueueSize(1), WithErrorHandler(func(event string, err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}))
+	var running, maxRunning, done atomic.Int32
+	release := make(chan struct{})
+	SubscribeTo(b, func(_ context.Context, e userCreated) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		<-release
+		done.Add(1)
+		if e.Id == 3 {
+			panic("async boom")
+		}
+		return nil
+	}, Async())
+
+	// 2 个协程处理中，1 个在队列中
+	for i := 1; i <= 3; i++ {
+		if err := PublishTo(b, context.Background(), userCreated{Id: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	// 队列已满，等待到 ctx 结束
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := PublishTo(b, ctx, userCreated{Id: 4}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+
+	close(release)
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if done.Load() != 3 || maxRunning.Load() != 2 {
+		t.Fatalf("done %d, max running %d", done.Load(), maxRunning.Load())
+	}
+	var pe *PanicError
+	if len(errs) != 1 || !errors.As(errs[0], &pe) {
+		t.Fatalf("errs = %v", errs)
+	}
+	if err := PublishTo(b, context.Background(), userCreated{Id: 5}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("after close err = %v", err)
+	}
+}
+
+func TestWait(t *testing.T) {
+	b := NewBus(WithWorkers(1))
+	release := make(chan struct{})
+	SubscribeTo(b, func(_ context.Context, e userCreated) error {
+		<-release
+		return nil
+	}, Async())
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := PublishTo(b, context.Background(), userCreated{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+
+	// 等待时可以继续发布
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			_ = PublishTo(b, context.Background(), userCreated{Id: id})
+		}(i)
+	}
+	close(release)
+	wg.Wait()
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishFromWorker(t *testing.T) {
+	b := NewBus(WithWorkers(1), WithQueueSize(1))
+	errs := make(chan error, 2)
+	SubscribeTo(b, func(ctx context.Context, e userCreated) error {
+		// 唯一的协程在处理中，队列满时不等待
+		errs <- PublishTo(b, ctx, DbAdded{Key: "a"})
+		errs <- PublishTo(b, ctx, DbAdded{Key: "b"})
+		return nil
+	}, Async())
+	var handled atomic.Int32
+	SubscribeTo(b, func(_ context.Context, e DbAdded) error {
+		handled.Add(1)
+		return nil
+	}, Async())
+
+	before := runtime.NumGoroutine()
+	if err := PublishTo(b, context.Background(), userCreated{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("err = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if handled.Load() != 1 {
+		t.Fatalf("handled %d", handled.Load())
+	}
+	// 关闭后协程退出
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("workers not stopped, goroutines %d -> %d", before, n)
+	}
+}
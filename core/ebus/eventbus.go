@@ -2,10 +2,12 @@ package ebus
 
 import "github.com/mooncake9527/x/eventbus"
 
+// EventBus 字符串主题的事件总线，仅为兼容旧的订阅方式保留，没有事件数据（EventConfigChanged 除外），新代码请使用 Subscribe
 var EventBus = eventbus.New()
 
 const (
 	EventApplicationStarted = "application:started"
 	EventApplicationQuit    = "application:quit"
 	EventCoreInit           = "application:core:init"
+	EventConfigChanged      = "application:config:changed" //远程配置变更，参数为 ConfigChanged
 )
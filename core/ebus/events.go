@@ -0,0 +1,54 @@
+package ebus
+
+import (
+	"time"
+
+	"github.com/mooncake9527/npx/config"
+)
+
+// 应用生命周期事件，通过 ebus.Subscribe 订阅
+// e.g. ebus.Subscribe(func(ctx context.Context, e ebus.DbAdded) error { ... })
+
+// CoreInit 组件启动完成，处理函数返回错误时启动失败
+type CoreInit struct {
+	Components []string //已启动的组件
+}
+
+// AppStarted 服务开始监听
+type AppStarted struct {
+	Addr   string //监听地址
+	Scheme string //http、https
+}
+
+// AppQuit 开始关闭
+type AppQuit struct {
+	Reason string //关闭原因 e.g. signal: terminated
+	Err    error  //服务异常退出时的错误
+}
+
+// AppStopped 关闭完成
+type AppStopped struct {
+	Elapsed time.Duration //关闭耗时
+	Err     error         //关闭过程中的错误
+}
+
+// ConfigChanged 远程配置变更
+type ConfigChanged struct {
+	Old config.AppCfg
+	New config.AppCfg
+}
+
+// DbAdded 运行时新增db
+type DbAdded struct {
+	Key string
+}
+
+// DbReplaced 运行时替换db，包括 ReloadDb
+type DbReplaced struct {
+	Key string
+}
+
+// DbRemoved 运行时移除db
+type DbRemoved struct {
+	Key string
+}
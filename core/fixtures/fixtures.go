@@ -0,0 +1,288 @@
+// Package fixtures 从 YAML/JSON 文件加载测试与开发数据
+//
+// 文件名（不含扩展名）为表名，内容为行列表：
+//
+//	# sys_user.yml
+//	- id: 1
+//	  username: admin
+//
+// 也可以在一个文件中按表名分组：
+//
+//	sys_user:
+//	  - id: 1
+//	sys_dept:
+//	  - id: 1
+//
+// Load、Insert 加载前清空涉及的表，保证每次测试的数据一致，只用于测试
+// InsertIfEmpty 只写入空表，不删除已有数据，用于开发环境启动时的初始数据
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Fixture 一张表的数据
+type Fixture struct {
+	Table string
+	Rows  []map[string]any
+}
+
+// Load 加载 fsys 中的文件或目录（目录只读取一层），在一个事务中清空涉及的表并写入，返回写入的表
+func Load(db *gorm.DB, fsys fs.FS, paths ...string) ([]string, error) {
+	fixtures, err := Read(fsys, paths...)
+	if err != nil {
+		return nil, err
+	}
+	return Insert(db, fixtures...)
+}
+
+// LoadDir 加载本地目录下的文件
+func LoadDir(db *gorm.DB, dir string) ([]string, error) {
+	return Load(db, os.DirFS(dir), ".")
+}
+
+// Read 读取文件或目录，按文件名顺序返回
+func Read(fsys fs.FS, paths ...string) ([]Fixture, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := fs.Stat(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := fs.ReadDir(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && isFixture(e.Name()) {
+				files = append(files, path.Join(p, e.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	var res []Fixture
+	for _, f := range files {
+		b, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		fs, err := Parse(f, b)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, fs...)
+	}
+	return res, nil
+}
+
+func isFixture(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yml", ".yaml", ".json":
+		return true
+	}
+	return false
+}
+
+// Parse 按扩展名解析文件内容，行列表时表名取文件名
+func Parse(name string, b []byte) ([]Fixture, error) {
+	var data any
+	var err error
+	if strings.ToLower(path.Ext(name)) == ".json" {
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		err = d.Decode(&data)
+	} else {
+		err = yaml.Unmarshal(b, &data)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse fixture %s", name)
+	}
+	switch v := data.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		table := strings.TrimSuffix(path.Base(name), path.Ext(name))
+		rows, err := toRows(name, table, v)
+		if err != nil {
+			return nil, err
+		}
+		return []Fixture{{Table: table, Rows: rows}}, nil
+	case map[string]any:
+		tables := make([]string, 0, len(v))
+		for t := range v {
+			tables = append(tables, t)
+		}
+		sort.Strings(tables)
+		res := make([]Fixture, 0, len(tables))
+		for _, t := range tables {
+			list, ok := v[t].([]any)
+			if !ok && v[t] != nil {
+				return nil, errors.Errorf("fixture %s table %s should be a list", name, t)
+			}
+			rows, err := toRows(name, t, list)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, Fixture{Table: t, Rows: rows})
+		}
+		return res, nil
+	default:
+		return nil, errors.Errorf("fixture %s should be a list or a map of tables", name)
+	}
+}
+
+func toRows(name, table string, list []any) ([]map[string]any, error) {
+	rows := make([]map[string]any, 0, len(list))
+	for i, item := range list {
+		row, ok := item.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("fixture %s table %s row %d should be a map", name, table, i)
+		}
+		for k, v := range row {
+			cv, err := normalize(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "fixture %s table %s row %d column %s", name, table, i, k)
+			}
+			row[k] = cv
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// normalize json 数字转为整数或浮点数，对象和数组转为 JSON 字符串
+func normalize(v any) (any, error) {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i, nil
+		}
+		return val.Float64()
+	case map[string]any, []any:
+		b, err := json.Marshal(jsonCompatible(val))
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+	return v, nil
+}
+
+// jsonCompatible yaml 可能解析出 map[any]any 等 json 不支持的类型
+func jsonCompatible(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			val[k] = jsonCompatible(item)
+		}
+		return val
+	case map[any]any:
+		m := make(map[string]any, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = jsonCompatible(item)
+		}
+		return m
+	case []any:
+		for i, item := range val {
+			val[i] = jsonCompatible(item)
+		}
+		return val
+	}
+	return v
+}
+
+// Insert 在一个事务中清空涉及的表并写入，同一张表出现多次时只清空一次
+func Insert(db *gorm.DB, fixtures ...Fixture) ([]string, error) {
+	var tables []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		cleared := make(map[string]bool)
+		for _, f := range fixtures {
+			if !cleared[f.Table] {
+				if err := clear(tx, f.Table); err != nil {
+					return err
+				}
+				cleared[f.Table] = true
+				tables = append(tables, f.Table)
+			}
+			for _, row := range f.Rows {
+				if err := tx.Table(f.Table).Create(row).Error; err != nil {
+					return errors.Wrapf(err, "insert fixture %s", f.Table)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// InsertIfEmpty 在一个事务中只向没有数据的表写入，已有数据的表跳过，返回写入的表
+func InsertIfEmpty(db *gorm.DB, fixtures ...Fixture) ([]string, error) {
+	var tables []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		empty := make(map[string]bool)
+		for _, f := range fixtures {
+			ok, checked := empty[f.Table]
+			if !checked {
+				var n int64
+				if err := tx.Table(f.Table).Limit(1).Count(&n).Error; err != nil {
+					return errors.Wrapf(err, "count fixture %s", f.Table)
+				}
+				ok = n == 0
+				empty[f.Table] = ok
+				if ok {
+					tables = append(tables, f.Table)
+				}
+			}
+			if !ok {
+				continue
+			}
+			for _, row := range f.Rows {
+				if err := tx.Table(f.Table).Create(row).Error; err != nil {
+					return errors.Wrapf(err, "insert fixture %s", f.Table)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// Reset 清空表，用于测试之间隔离数据
+func Reset(db *gorm.DB, tables ...string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range tables {
+			if err := clear(tx, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func clear(tx *gorm.DB, table string) error {
+	if err := tx.Exec("DELETE FROM " + tx.Statement.Quote(table)).Error; err != nil {
+		return errors.Wrapf(err, "clear table %s", table)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package fixtures
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type user struct {
+	Id    int
+	Name  string
+	Score float64
+	Tags  string
+}
+
+type dept struct {
+	Id   int
+	Name string
+}
+
+func openDb(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&user{}, &dept{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestLoad(t *testing.T) {
+	db := openDb(t, "fixtures")
+	fsys := fstest.MapFS{
+		"data/users.yml": {Data: []byte("- id: 1\n  name: a\n  score: 1.5\n  tags: [x, y]\n- id: 2\n  name: b\n")},
+		"data/all.json":  {Data: []byte(`{"depts": [{"id": 1, "name": "d1"}], "users": [{"id": 3, "name": "c"}]}`)},
+		"data/readme.md": {Data: []byte("ignored")},
+	}
+	db.Create(&user{Id: 9, Name: "old"})
+
+	tables, err := Load(db, fsys, "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 || tables[0] != "depts" || tables[1] != "users" {
+		t.Errorf("tables %v", tables)
+	}
+	var us []user
+	db.Order("id").Find(&us)
+	if len(us) != 3 || us[0].Id != 1 || us[2].Id != 3 {
+		t.Fatalf("users %+v", us)
+	}
+	if us[0].Score != 1.5 || us[0].Tags != `["x","y"]` {
+		t.Errorf("user values %+v", us[0])
+	}
+	var n int64
+	db.Model(&dept{}).Count(&n)
+	if n != 1 {
+		t.Errorf("depts %d", n)
+	}
+
+	// 加载失败时整体回滚
+	fsys["data/zz.yml"] = &fstest.MapFile{Data: []byte("missing:\n  - id: 1\n")}
+	if _, err = Load(db, fsys, "data"); err == nil {
+		t.Fatal("missing table should fail")
+	}
+	db.Model(&user{}).Count(&n)
+	if n != 3 {
+		t.Errorf("failed load should roll back, users %d", n)
+	}
+
+	if err = Reset(db, "users", "depts"); err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&user{}).Count(&n)
+	if n != 0 {
+		t.Errorf("reset users %d", n)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if _, err := Parse("a.yml", []byte("- 1\n")); err == nil {
+		t.Error("row should be a map")
+	}
+	if _, err := Parse("a.json", []byte(`"x"`)); err == nil {
+		t.Error("scalar should fail")
+	}
+	fs, err := Parse("a.json", []byte(`[{"id": 10000000000, "v": 0.25}]`))
+	if err != nil || fs[0].Table != "a" || fs[0].Rows[0]["id"] != int64(10000000000) || fs[0].Rows[0]["v"] != 0.25 {
+		t.Errorf("parse %+v %v", fs, err)
+	}
+}
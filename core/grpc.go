@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/mooncake9527/npx/common/trace"
+	"github.com/mooncake9527/npx/grpc/pb/health"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// GrpcComponent grpc 服务组件，按 grpc-server 配置监听，未开启时不启动
+// 内置链路拦截器与健康检查服务，关闭时与 http 服务同时排空，超时后强制停止
+//
+//	_ = core.RegisterComponent(core.NewGrpcComponent(func(s *grpc.Server) {
+//		pb.RegisterGreeterServer(s, &greeter{})
+//	}, core.ComponentDb))
+type GrpcComponent struct {
+	register func(s *grpc.Server)
+	deps     []string
+	opts     []grpc.ServerOption
+
+	server *grpc.Server
+	addr   net.Addr
+}
+
+// NewGrpcComponent register 注册业务服务，deps 为依赖的组件名
+func NewGrpcComponent(register func(s *grpc.Server), deps ...string) *GrpcComponent {
+	return &GrpcComponent{register: register, deps: append([]string{ComponentLog}, deps...)}
+}
+
+// WithServerOptions 追加 grpc.ServerOption，需在 Start 前调用
+func (c *GrpcComponent) WithServerOptions(opts ...grpc.ServerOption) *GrpcComponent {
+	c.opts = append(c.opts, opts...)
+	return c
+}
+
+func (c *GrpcComponent) Name() string {
+	return ComponentGrpc
+}
+
+func (c *GrpcComponent) DependsOn() []string {
+	return c.deps
+}
+
+func (c *GrpcComponent) Start(context.Context) error {
+	gc := Cfg.GrpcServer
+	if !gc.Enable {
+		slog.Info("grpc server disabled")
+		return nil
+	}
+	addr := fmt.Sprintf("%s:%d", gc.GetHost(), gc.GetPort())
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "grpc listen")
+	}
+	opts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(trace.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(trace.StreamServerInterceptor()),
+	}, c.opts...)
+	s := grpc.NewServer(opts...)
+	health.RegisterHealthServer(s, &health.HealthServerImpl{Ready: Ready})
+	if c.register != nil {
+		c.register(s)
+	}
+	c.server, c.addr = s, ln.Addr()
+	go func() {
+		if err := s.Serve(ln); err != nil {
+			slog.Error("grpc server err", "err", err)
+		}
+	}()
+	slog.Info("grpc server started", "addr", c.addr.String())
+	return nil
+}
+
+// Addr 监听地址，未启动时为 nil
+func (c *GrpcComponent) Addr() net.Addr {
+	return c.addr
+}
+
+// Drain 等待进行中的调用完成，ctx 结束时强制停止
+func (c *GrpcComponent) Drain(ctx context.Context) error {
+	if c.server == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		c.server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		c.server.Stop()
+		return ctx.Err()
+	}
+}
+
+func (c *GrpcComponent) Stop(context.Context) error {
+	if c.server == nil {
+		return nil
+	}
+	c.server.Stop()
+	c.server, c.addr = nil, nil
+	return nil
+}
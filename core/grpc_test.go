@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mooncake9527/npx/grpc/pb/health"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestGrpcComponent(t *testing.T) {
+	old := Cfg
+	defer func() { Cfg = old }()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+	Cfg.GrpcServer.Enable = true
+	Cfg.GrpcServer.Host = "127.0.0.1"
+	Cfg.GrpcServer.Port = port
+	SetReady(true)
+	defer SetReady(false)
+
+	registered := false
+	c := NewGrpcComponent(func(s *grpc.Server) { registered = true })
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !registered {
+		t.Fatal("register not called")
+	}
+	conn, err := grpc.NewClient(c.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	res, err := health.NewHealthClient(conn).Check(context.Background(), &health.HealthCheckRequest{})
+	if err != nil || res.Status != health.HealthCheckResponse_SERVING {
+		t.Fatalf("health %v, err %v", res, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := health.NewHealthClient(conn).Check(ctx, &health.HealthCheckRequest{}); err == nil {
+		t.Fatal("server should be stopped")
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	runs := make(chan struct{}, 10)
+	s := NewScheduler("jobs")
+	s.Every(10*time.Millisecond, "tick", func(ctx context.Context) error {
+		runs <- struct{}{}
+		return nil
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatal("job not run")
+		}
+	}
+
+	blocked := make(chan struct{})
+	s.Every(10*time.Millisecond, "wait", func(ctx context.Context) error {
+		close(blocked)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-blocked
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for len(runs) > 0 {
+		<-runs
+	}
+	time.Sleep(30 * time.Millisecond)
+	if len(runs) > 0 {
+		t.Fatal("job should stop")
+	}
+}
@@ -0,0 +1,387 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStopTimeout 组件停止的默认超时
+const DefaultStopTimeout = 10 * time.Second
+
+// Component 应用组件，按依赖顺序启动，逆序停止
+type Component interface {
+	Name() string
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Dependent 组件依赖，返回依赖的组件名，依赖的组件先启动后停止
+type Dependent interface {
+	DependsOn() []string
+}
+
+// StopTimeouter 组件自定义停止超时，未实现时使用 DefaultStopTimeout
+type StopTimeouter interface {
+	StopTimeout() time.Duration
+}
+
+// PreStopper 关闭时最先调用，用于注销服务发现等，之后不再有新流量进入
+type PreStopper interface {
+	PreStop(ctx context.Context) error
+}
+
+// Drainer 关闭时与 http 服务排空同时调用，等待进行中的请求完成 e.g. grpc GracefulStop
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
+type ComponentOption func(*funcComponent)
+
+// WithDependsOn 设置依赖的组件
+func WithDependsOn(names ...string) ComponentOption {
+	return func(c *funcComponent) {
+		c.deps = append(c.deps, names...)
+	}
+}
+
+// WithPreStop 设置关闭时最先执行的函数，见 PreStopper
+func WithPreStop(fn func(ctx context.Context) error) ComponentOption {
+	return func(c *funcComponent) {
+		c.preStop = fn
+	}
+}
+
+// WithDrain 设置关闭时等待进行中请求的函数，见 Drainer
+func WithDrain(fn func(ctx context.Context) error) ComponentOption {
+	return func(c *funcComponent) {
+		c.drain = fn
+	}
+}
+
+// WithStopTimeout 设置停止超时
+func WithStopTimeout(d time.Duration) ComponentOption {
+	return func(c *funcComponent) {
+		c.stopTimeout = d
+	}
+}
+
+type funcComponent struct {
+	name        string
+	deps        []string
+	stopTimeout time.Duration
+	start, stop func(ctx context.Context) error
+	preStop     func(ctx context.Context) error
+	drain       func(ctx context.Context) error
+}
+
+// NewComponent 由函数创建组件，start、stop 可为 nil
+func NewComponent(name string, start, stop func(ctx context.Context) error, opts ...ComponentOption) Component {
+	c := &funcComponent{name: name, start: start, stop: stop}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *funcComponent) Name() string {
+	return c.name
+}
+
+func (c *funcComponent) DependsOn() []string {
+	return c.deps
+}
+
+func (c *funcComponent) StopTimeout() time.Duration {
+	return c.stopTimeout
+}
+
+func (c *funcComponent) Start(ctx context.Context) error {
+	if c.start == nil {
+		return nil
+	}
+	return c.start(ctx)
+}
+
+func (c *funcComponent) PreStop(ctx context.Context) error {
+	if c.preStop == nil {
+		return nil
+	}
+	return c.preStop(ctx)
+}
+
+func (c *funcComponent) Drain(ctx context.Context) error {
+	if c.drain == nil {
+		return nil
+	}
+	return c.drain(ctx)
+}
+
+func (c *funcComponent) Stop(ctx context.Context) error {
+	if c.stop == nil {
+		return nil
+	}
+	return c.stop(ctx)
+}
+
+// Lifecycle 组件生命周期，依赖相同时按注册顺序启动
+// 组件的启动与停止在锁外调用，组件中可以查询 Components、Started
+type Lifecycle struct {
+	op      sync.Mutex //串行化 Start 与 Stop
+	mu      sync.Mutex //保护以下字段
+	comps   []Component
+	started []Component
+	running bool
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Register 注册组件，名称重复或已启动时返回错误
+func (l *Lifecycle) Register(comps ...Component) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running {
+		return errors.New("lifecycle: already started")
+	}
+	for _, c := range comps {
+		if l.find(c.Name()) != nil {
+			return fmt.Errorf("lifecycle: component %s already registered", c.Name())
+		}
+		l.comps = append(l.comps, c)
+	}
+	return nil
+}
+
+// Components 按注册顺序返回组件名
+func (l *Lifecycle) Components() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := make([]string, len(l.comps))
+	for i, c := range l.comps {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// Started 按启动顺序返回已启动的组件
+func (l *Lifecycle) Started() []Component {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Component(nil), l.started...)
+}
+
+func (l *Lifecycle) find(name string) Component {
+	for _, c := range l.comps {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// order 拓扑排序，依赖不存在或循环依赖时返回错误
+func (l *Lifecycle) order() ([]Component, error) {
+	for _, c := range l.comps {
+		for _, dep := range dependsOn(c) {
+			if l.find(dep) == nil {
+				return nil, fmt.Errorf("lifecycle: component %s depends on unknown %s", c.Name(), dep)
+			}
+		}
+	}
+	done := make(map[string]bool, len(l.comps))
+	res := make([]Component, 0, len(l.comps))
+	for len(res) < len(l.comps) {
+		n := len(res)
+		for _, c := range l.comps {
+			if done[c.Name()] || !allDone(dependsOn(c), done) {
+				continue
+			}
+			done[c.Name()] = true
+			res = append(res, c)
+			break
+		}
+		if len(res) == n {
+			var cycle []string
+			for _, c := range l.comps {
+				if !done[c.Name()] {
+					cycle = append(cycle, c.Name())
+				}
+			}
+			return nil, fmt.Errorf("lifecycle: dependency cycle among %s", strings.Join(cycle, ", "))
+		}
+	}
+	return res, nil
+}
+
+func dependsOn(c Component) []string {
+	if d, ok := c.(Dependent); ok {
+		return d.DependsOn()
+	}
+	return nil
+}
+
+func allDone(deps []string, done map[string]bool) bool {
+	for _, dep := range deps {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// Start 按依赖顺序启动组件，失败时逆序停止已启动的组件并返回错误
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.op.Lock()
+	defer l.op.Unlock()
+	l.mu.Lock()
+	if l.running {
+		l.mu.Unlock()
+		return errors.New("lifecycle: already started")
+	}
+	comps, err := l.order()
+	if err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	l.running = true
+	l.mu.Unlock()
+	for _, c := range comps {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(fmt.Errorf("start %s: %w", c.Name(), err), l.stop())
+		}
+		start := time.Now()
+		if err := safeCall(c.Start, ctx); err != nil {
+			slog.Error("component start err", "name", c.Name(), "err", err)
+			return errors.Join(fmt.Errorf("start %s: %w", c.Name(), err), l.stop())
+		}
+		l.mu.Lock()
+		l.started = append(l.started, c)
+		l.mu.Unlock()
+		slog.Debug("component started", "name", c.Name(), "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+// PreStop 逆序调用已启动组件的 PreStop，返回所有错误
+func (l *Lifecycle) PreStop(ctx context.Context) error {
+	started := l.Started()
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		c, ok := started[i].(PreStopper)
+		if !ok {
+			continue
+		}
+		if err := safeCall(c.PreStop, ctx); err != nil {
+			slog.Error("component pre stop err", "name", started[i].Name(), "err", err)
+			errs = append(errs, fmt.Errorf("pre stop %s: %w", started[i].Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Drain 并发调用已启动组件的 Drain，共用 ctx 的截止时间，返回所有错误
+func (l *Lifecycle) Drain(ctx context.Context) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, c := range l.Started() {
+		d, ok := c.(Drainer)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := safeCall(d.Drain, ctx); err != nil {
+				slog.Error("component drain err", "name", name, "err", err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("drain %s: %w", name, err))
+				mu.Unlock()
+			}
+		}(c.Name())
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	// 超时后不再等待组件返回
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if ctx.Err() != nil && len(errs) == 0 {
+		return fmt.Errorf("drain: %w", ctx.Err())
+	}
+	return errors.Join(errs...)
+}
+
+// Stop 逆序停止已启动的组件，每个组件有独立超时，返回所有错误
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.op.Lock()
+	defer l.op.Unlock()
+	return l.stopCtx(ctx)
+}
+
+func (l *Lifecycle) stop() error {
+	return l.stopCtx(context.Background())
+}
+
+// stopCtx 调用方持有 op，停止过程中 running 仍为 true，不能注册新组件
+func (l *Lifecycle) stopCtx(ctx context.Context) error {
+	l.mu.Lock()
+	started := l.started
+	l.started = nil
+	l.mu.Unlock()
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		if err := stopComponent(ctx, c); err != nil {
+			slog.Error("component stop err", "name", c.Name(), "err", err)
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.Name(), err))
+		}
+	}
+	l.mu.Lock()
+	l.running = false
+	l.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// stopComponent 超时后不再等待组件返回
+func stopComponent(ctx context.Context, c Component) error {
+	timeout := DefaultStopTimeout
+	if t, ok := c.(StopTimeouter); ok && t.StopTimeout() > 0 {
+		timeout = t.StopTimeout()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- safeCall(c.Stop, ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func safeCall(fn func(ctx context.Context) error, ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
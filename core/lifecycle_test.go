@@ -0,0 +1,212 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core/ebus"
+)
+
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) component(name string, startErr error, deps ...string) Component {
+	return NewComponent(name, func(context.Context) error {
+		r.events = append(r.events, "start "+name)
+		return startErr
+	}, func(context.Context) error {
+		r.events = append(r.events, "stop "+name)
+		return nil
+	}, WithDependsOn(deps...))
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	r := &recorder{}
+	l := NewLifecycle()
+	err := l.Register(
+		r.component("grpc", nil, "db", "cache"),
+		r.component("db", nil, "log"),
+		r.component("cache", nil, "log"),
+		r.component("log", nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Register(r.component("db", nil)); err == nil {
+		t.Fatal("expected duplicate error")
+	}
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start log", "start db", "start cache", "start grpc", "stop grpc", "stop cache", "stop db", "stop log"}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Fatalf("events = %v, want %v", r.events, want)
+	}
+}
+
+func TestLifecycleStartError(t *testing.T) {
+	r := &recorder{}
+	l := NewLifecycle()
+	_ = l.Register(
+		r.component("log", nil),
+		r.component("db", errors.New("refused"), "log"),
+		r.component("grpc", nil, "db"),
+	)
+	err := l.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "start db: refused") {
+		t.Fatalf("err = %v", err)
+	}
+	want := []string{"start log", "start db", "stop log"}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Fatalf("events = %v, want %v", r.events, want)
+	}
+	// 失败后可以重新启动，db 仍然失败
+	if err := l.Start(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLifecycleDependencyError(t *testing.T) {
+	l := NewLifecycle()
+	_ = l.Register(NewComponent("a", nil, nil, WithDependsOn("none")))
+	if err := l.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "unknown none") {
+		t.Fatalf("err = %v", err)
+	}
+
+	l = NewLifecycle()
+	_ = l.Register(
+		NewComponent("a", nil, nil, WithDependsOn("b")),
+		NewComponent("b", nil, nil, WithDependsOn("a")),
+		NewComponent("c", nil, nil),
+	)
+	if err := l.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "cycle among a, b") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLifecycleHooksQuery(t *testing.T) {
+	l := NewLifecycle()
+	var started, stopping []Component
+	var registerErr error
+	_ = l.Register(NewComponent("a", func(context.Context) error {
+		started = l.Started()
+		registerErr = l.Register(NewComponent("b", nil, nil))
+		return nil
+	}, func(context.Context) error {
+		_ = l.Components()
+		stopping = l.Started()
+		return nil
+	}))
+	done := make(chan error, 1)
+	go func() {
+		done <- errors.Join(l.Start(context.Background()), l.Stop(context.Background()))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hooks querying the lifecycle should not deadlock")
+	}
+	if len(started) != 0 || len(stopping) != 0 || registerErr == nil {
+		t.Errorf("started %v, stopping %v, register %v", started, stopping, registerErr)
+	}
+}
+
+func TestLifecycleStopTimeout(t *testing.T) {
+	stopped := false
+	l := NewLifecycle()
+	_ = l.Register(
+		NewComponent("fast", nil, func(context.Context) error {
+			stopped = true
+			return nil
+		}),
+		NewComponent("slow", nil, func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}, WithStopTimeout(20*time.Millisecond)),
+		NewComponent("panic", nil, func(context.Context) error {
+			panic("boom")
+		}),
+	)
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	begin := time.Now()
+	err := l.Stop(context.Background())
+	if time.Since(begin) > 500*time.Millisecond {
+		t.Fatal("stop waited for slow component")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "stop panic: panic: boom") {
+		t.Fatalf("err = %v", err)
+	}
+	if !stopped {
+		t.Fatal("fast not stopped")
+	}
+}
+
+func TestStartReportsError(t *testing.T) {
+	old := Cfg
+	defer func() {
+		Cfg = old
+		ResetState()
+	}()
+	ResetState()
+	Cfg = config.AppCfg{}
+	Cfg.Logger.Director = t.TempDir()
+	Cfg.DBCfg.Driver = Sqlite.String()
+	Cfg.DBCfg.LogMode = "silent"
+	Cfg.DBCfg.DSN = "file:lifecycle?mode=memory&cache=shared"
+	Cfg.DBCfg.Seed = t.TempDir() + "/none"
+	Cfg.Server.Mode = ModeTest.String()
+	Cfg.Extends = map[string]any{"app": map[string]any{"name": "x"}}
+
+	var started []string
+	if err := RegisterComponent(NewComponent("worker", func(context.Context) error {
+		started = append(started, "worker")
+		return nil
+	}, nil, WithDependsOn(ComponentDb))); err != nil {
+		t.Fatal(err)
+	}
+	err := Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "start db") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(started) > 0 || Cache != nil || Locker != nil || len(Dbs()) > 0 {
+		t.Fatal("components not rolled back")
+	}
+
+	Cfg.DBCfg.Seed = ""
+	if err := Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	type app struct {
+		Name string `mapstructure:"name"`
+	}
+	if a, err := config.Extend[app]("app"); err != nil || a.Name != "x" {
+		t.Errorf("extend %+v %v", a, err)
+	}
+	if Cache == nil || Locker == nil || len(Dbs()) != 1 || len(started) != 1 {
+		t.Fatal("components not started")
+	}
+	if err := Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if Cache != nil || len(Dbs()) > 0 || ebus.HasSubscriber[ebus.ConfigChanged](ebus.Default) {
+		t.Fatal("components not stopped")
+	}
+	ResetState()
+	if a, _ := config.Extend[app]("app"); a.Name != "" {
+		t.Error("extend should be cleared on reset")
+	}
+}
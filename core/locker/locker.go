@@ -0,0 +1,27 @@
+package locker
+
+import (
+	"context"
+	"time"
+
+	"github.com/bsm/redislock"
+)
+
+var (
+	// ErrNotObtained 锁被占用或续期失败
+	ErrNotObtained = redislock.ErrNotObtained
+	// ErrLockNotHeld 释放时锁已过期或被他人持有
+	ErrLockNotHeld = redislock.ErrLockNotHeld
+)
+
+// Locker 锁，Redis 实现用于多实例，Memory 实现用于单实例与测试
+type Locker interface {
+	Obtain(ctx context.Context, key string, ttl time.Duration) (Lock, error)
+}
+
+// Lock 已获取的锁
+type Lock interface {
+	Key() string
+	Refresh(ctx context.Context, ttl time.Duration) error
+	Release(ctx context.Context) error
+}
@@ -0,0 +1,76 @@
+package locker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Memory 进程内的锁，只在单实例内互斥
+type Memory struct {
+	mu    sync.Mutex
+	locks map[string]memoryEntry
+	seq   atomic.Int64
+}
+
+type memoryEntry struct {
+	token  string
+	expire time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{locks: make(map[string]memoryEntry)}
+}
+
+func (*Memory) String() string {
+	return "memory"
+}
+
+// Obtain 获取锁，已被占用且未过期时返回 ErrNotObtained
+func (m *Memory) Obtain(_ context.Context, key string, ttl time.Duration) (Lock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.locks[key]; ok && time.Now().Before(e.expire) {
+		return nil, ErrNotObtained
+	}
+	token := strconv.FormatInt(m.seq.Add(1), 10)
+	m.locks[key] = memoryEntry{token: token, expire: time.Now().Add(ttl)}
+	return &memoryLock{m: m, key: key, token: token}, nil
+}
+
+type memoryLock struct {
+	m     *Memory
+	key   string
+	token string
+}
+
+func (l *memoryLock) Key() string {
+	return l.key
+}
+
+func (l *memoryLock) Refresh(_ context.Context, ttl time.Duration) error {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+	if !l.held() {
+		return ErrNotObtained
+	}
+	l.m.locks[l.key] = memoryEntry{token: l.token, expire: time.Now().Add(ttl)}
+	return nil
+}
+
+func (l *memoryLock) Release(context.Context) error {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+	if !l.held() {
+		return ErrLockNotHeld
+	}
+	delete(l.m.locks, l.key)
+	return nil
+}
+
+func (l *memoryLock) held() bool {
+	e, ok := l.m.locks[l.key]
+	return ok && e.token == l.token && time.Now().Before(e.expire)
+}
@@ -0,0 +1,35 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	l, err := m.Obtain(ctx, "a", 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Obtain(ctx, "a", time.Minute); !errors.Is(err, ErrNotObtained) {
+		t.Errorf("held lock err %v", err)
+	}
+	if err = l.Refresh(ctx, 20*time.Millisecond); err != nil {
+		t.Errorf("refresh %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	// 过期后可被他人获取，原持有者释放失败
+	l2, err := m.Obtain(ctx, "a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Release(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("expired release err %v", err)
+	}
+	if err = l2.Release(ctx); err != nil {
+		t.Errorf("release %v", err)
+	}
+}
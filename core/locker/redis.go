@@ -3,6 +3,7 @@ package locker
 import (
 	"context"
 	"github.com/pkg/errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -10,6 +11,18 @@ import (
 	"github.com/bsm/redislock"
 )
 
+// observer 指标开启、关闭时替换，与加锁并发
+var observer atomic.Pointer[func(key string, elapsed time.Duration, err error)]
+
+// SetObserver 设置获取锁的耗时回调，用于指标统计，nil 时取消，可在运行中设置
+func SetObserver(fn func(key string, elapsed time.Duration, err error)) {
+	if fn == nil {
+		observer.Store(nil)
+		return
+	}
+	observer.Store(&fn)
+}
+
 func NewRedis(c redis.UniversalClient) *Redis {
 	return &Redis{
 		client: c,
@@ -26,11 +39,50 @@ func (Redis) String() string {
 }
 
 func (r *Redis) Lock(key string, ttl time.Duration, options *redislock.Options) (*redislock.Lock, error) {
+	return r.obtain(context.TODO(), key, ttl, options)
+}
+
+// LockContext 同 Lock，ctx 结束时停止重试并返回
+func (r *Redis) LockContext(ctx context.Context, key string, ttl time.Duration, options *redislock.Options) (*redislock.Lock, error) {
+	return r.obtain(ctx, key, ttl, options)
+}
+
+func (r *Redis) obtain(ctx context.Context, key string, ttl time.Duration, options *redislock.Options) (*redislock.Lock, error) {
 	if r.client == nil {
 		return nil, errors.New("redis client is nil")
 	}
 	if r.mutex == nil {
 		r.mutex = redislock.New(r.client)
 	}
-	return r.mutex.Obtain(context.TODO(), key, ttl, options)
+	begin := time.Now()
+	l, err := r.mutex.Obtain(ctx, key, ttl, options)
+	if fn := observer.Load(); fn != nil {
+		(*fn)(key, time.Since(begin), err)
+	}
+	return l, err
+}
+
+// Obtain 实现 Locker，不重试
+func (r *Redis) Obtain(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	l, err := r.obtain(ctx, key, ttl, nil)
+	if err != nil {
+		return nil, err
+	}
+	return redisLock{l}, nil
+}
+
+type redisLock struct {
+	l *redislock.Lock
+}
+
+func (l redisLock) Key() string {
+	return l.l.Key()
+}
+
+func (l redisLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	return l.l.Refresh(ctx, ttl, nil)
+}
+
+func (l redisLock) Release(ctx context.Context) error {
+	return l.l.Release(ctx)
 }
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"gorm.io/gorm"
 	gormLogger "gorm.io/gorm/logger"
 )
 
@@ -149,11 +150,26 @@ func (l *xlogger) Error(ctx context.Context, msg string, data ...interface{}) {
 //
 //nolint:cyclop
 func (l *xlogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	if hasDbMetrics() {
+		sql, rows := fc()
+		fc = func() (string, int64) { return sql, rows }
+		r := SqlRecord{
+			Fingerprint: SqlFingerprint(sql),
+			SQL:         sql,
+			Elapsed:     elapsed,
+			Rows:        rows,
+			Slow:        l.SlowThreshold != 0 && elapsed > l.SlowThreshold,
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			r.Err = err
+		}
+		observeSql(r)
+	}
 	if l.LogLevel <= gormLogger.Silent {
 		return
 	}
 
-	elapsed := time.Since(begin)
 	switch {
 	case err != nil && l.LogLevel >= gormLogger.Error && (!errors.Is(err, ErrRecordNotFound) || !l.IgnoreRecordNotFoundError):
 		sql, rows := fc()
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/core/cache"
+	"github.com/mooncake9527/npx/core/locker"
+	"github.com/mooncake9527/npx/core/metrics"
+)
+
+var (
+	httpRequests = metrics.Default.NewCounter("npx_http_requests_total",
+		"HTTP requests by method, route and status.", "method", "route", "status")
+	httpDuration = metrics.Default.NewHistogram("npx_http_request_duration_seconds",
+		"HTTP request latency by method and route.", nil, "method", "route")
+	dbQueryDuration = metrics.Default.NewHistogram("npx_db_query_duration_seconds",
+		"GORM statement latency by operation.", nil, "op")
+	dbQueryErrors = metrics.Default.NewCounter("npx_db_query_errors_total",
+		"GORM statement errors by operation.", "op")
+	dbSlowQueries = metrics.Default.NewCounter("npx_db_slow_queries_total",
+		"GORM statements over the slow threshold by operation.", "op")
+	cacheRequests = metrics.Default.NewCounter("npx_cache_requests_total",
+		"ICache reads by cache type, operation and result.", "type", "op", "result")
+	lockDuration = metrics.Default.NewHistogram("npx_lock_acquire_duration_seconds",
+		"Distributed lock acquisition latency by result.", nil, "result")
+)
+
+const (
+	metricDbPoolConnections = "npx_db_pool_connections"
+	metricDbPoolWait        = "npx_db_pool_wait_total"
+)
+
+// metricsEngine 已挂载指标中间件与拉取接口的引擎，重复 Start 时不再挂载
+var metricsEngine *gin.Engine
+
+// initMetrics 开启指标收集并挂载拉取接口，需在注册业务路由前调用，开启 admin 时拉取接口在运维端口上
+// 同一引擎只挂载一次，其余注册由 stopMetrics 撤销
+func initMetrics() error {
+	r, err := ginEngine()
+	if err != nil {
+		return err
+	}
+	lock.Lock()
+	if metricsEngine != r {
+		r.Use(MetricsMiddleware())
+		if !Cfg.Admin.Enable {
+			r.GET(Cfg.Metrics.GetPath(), gin.WrapH(metrics.Default.Handler()))
+		}
+		metricsEngine = r
+	}
+	lock.Unlock()
+	removeDbMetrics(isPromDbMetrics)
+	AddDbMetrics(promDbMetrics{})
+	metrics.Default.NewGaugeFunc(metricDbPoolConnections, "DB pool connections by db and state.", []string{"db", "state"},
+		func(set func(v float64, labelValues ...string)) {
+			for key, st := range DbPoolStats() {
+				set(float64(st.OpenConnections), key, "open")
+				set(float64(st.InUse), key, "in_use")
+				set(float64(st.Idle), key, "idle")
+				set(float64(st.MaxOpenConnections), key, "max_open")
+			}
+		})
+	metrics.Default.NewGaugeFunc(metricDbPoolWait, "DB pool waits for a connection by db.", []string{"db"},
+		func(set func(v float64, labelValues ...string)) {
+			for key, st := range DbPoolStats() {
+				set(float64(st.WaitCount), key)
+			}
+		})
+	cacheType := Cache.Type()
+	cache.SetObserver(func(op string, hit bool) {
+		result := "miss"
+		if hit {
+			result = "hit"
+		}
+		cacheRequests.Inc(cacheType, op, result)
+	})
+	locker.SetObserver(func(_ string, elapsed time.Duration, err error) {
+		result := "ok"
+		if err != nil {
+			result = "fail"
+		}
+		lockDuration.Observe(elapsed.Seconds(), result)
+	})
+	return nil
+}
+
+// stopMetrics 撤销 initMetrics 的注册，中间件与拉取接口随引擎保留
+func stopMetrics(context.Context) error {
+	removeDbMetrics(isPromDbMetrics)
+	metrics.Default.Unregister(metricDbPoolConnections)
+	metrics.Default.Unregister(metricDbPoolWait)
+	cache.SetObserver(nil)
+	locker.SetObserver(nil)
+	return nil
+}
+
+// MetricsMiddleware 按路由模板统计请求数与耗时，未匹配的路由记为 unmatched
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		begin := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequests.Inc(method, route, strconv.Itoa(c.Writer.Status()))
+		httpDuration.Observe(time.Since(begin).Seconds(), method, route)
+	}
+}
+
+// promDbMetrics 将sql执行记录写入 Prometheus 指标
+type promDbMetrics struct{}
+
+func (promDbMetrics) ObserveSql(r SqlRecord) {
+	op := sqlOp(r.Fingerprint)
+	dbQueryDuration.Observe(r.Elapsed.Seconds(), op)
+	if r.Err != nil {
+		dbQueryErrors.Inc(op)
+	}
+	if r.Slow {
+		dbSlowQueries.Inc(op)
+	}
+}
+
+func isPromDbMetrics(m DbMetrics) bool {
+	_, ok := m.(promDbMetrics)
+	return ok
+}
+
+// sqlOp sql 的操作类型，避免以sql作为标签
+func sqlOp(sql string) string {
+	i := strings.IndexAny(sql, " \t\n(")
+	if i > 0 {
+		sql = sql[:i]
+	}
+	switch op := strings.ToLower(sql); op {
+	case "select", "insert", "update", "delete", "with":
+		return op
+	default:
+		return "other"
+	}
+}
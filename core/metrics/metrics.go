@@ -0,0 +1,381 @@
+// Package metrics 轻量的 Prometheus 指标，输出文本格式，不依赖官方客户端
+//
+//	reqs := metrics.Default.NewCounter("app_requests_total", "请求数", "route")
+//	reqs.Inc("/api/user")
+//	r.GET("/metrics", gin.WrapH(metrics.Default.Handler()))
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+
+	labelSep = "\xff"
+)
+
+// DefBuckets 默认的耗时桶（秒）
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default 默认注册表
+var Default = NewRegistry()
+
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry 指标注册表，同名指标重复注册时返回已注册的指标
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+func (r *Registry) register(c collector) collector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.collectors[c.name()]; ok {
+		return old
+	}
+	r.collectors[c.name()] = c
+	return c
+}
+
+// NewCounter 注册计数器
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, typeCounter, labels)}
+	if old, ok := r.register(c).(*Counter); ok {
+		return old
+	}
+	panic(fmt.Sprintf("metrics: %s registered with another type", name))
+}
+
+// NewGauge 注册仪表
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, typeGauge, labels)}
+	if old, ok := r.register(g).(*Gauge); ok {
+		return old
+	}
+	panic(fmt.Sprintf("metrics: %s registered with another type", name))
+}
+
+// NewGaugeFunc 注册采集时计算的仪表，fn 中调用 set 设置各标签的值
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, fn func(set func(v float64, labelValues ...string))) {
+	r.register(&gaugeFunc{help: help, labels: labels, n: name, fn: fn})
+}
+
+// NewHistogram 注册直方图，buckets 为空时使用 DefBuckets
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{vec: newVec(name, help, typeHistogram, labels), buckets: buckets}
+	if old, ok := r.register(h).(*Histogram); ok {
+		return old
+	}
+	panic(fmt.Sprintf("metrics: %s registered with another type", name))
+}
+
+// Unregister 移除指标
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.collectors, name)
+}
+
+// Write 按名称顺序输出所有指标
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.RLock()
+	cs := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		cs = append(cs, c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].name() < cs[j].name()
+	})
+	bw := bufio.NewWriter(w)
+	for _, c := range cs {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler Prometheus 拉取接口
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
+
+// vec 按标签值分组的序列
+type vec struct {
+	n, help, typ string
+	labels       []string
+	mu           sync.RWMutex
+	series       map[string]*series
+}
+
+type series struct {
+	values []string
+	mu     sync.Mutex
+	value  float64
+	counts []uint64 //直方图各桶计数（非累计），最后一个为 +Inf
+	sum    float64
+	count  uint64
+}
+
+func newVec(name, help, typ string, labels []string) vec {
+	return vec{n: name, help: help, typ: typ, labels: labels, series: make(map[string]*series)}
+}
+
+func (v *vec) name() string {
+	return v.n
+}
+
+func (v *vec) get(values []string, init func(s *series)) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.n, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, labelSep)
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok = v.series[key]; ok {
+		return s
+	}
+	s = &series{values: append([]string(nil), values...)}
+	if init != nil {
+		init(s)
+	}
+	v.series[key] = s
+	return s
+}
+
+// sorted 按标签值排序的序列，保证输出稳定
+func (v *vec) sorted() []*series {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]*series, len(keys))
+	for i, k := range keys {
+		res[i] = v.series[k]
+	}
+	v.mu.RUnlock()
+	return res
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	if help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+// Counter 只增的计数器
+type Counter struct {
+	vec
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 增加计数，v 不能为负
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	s := c.get(labelValues, nil)
+	s.mu.Lock()
+	s.value += v
+	s.mu.Unlock()
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	writeValues(w, &c.vec)
+}
+
+// Gauge 可增减的仪表
+type Gauge struct {
+	vec
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	s := g.get(labelValues, nil)
+	s.mu.Lock()
+	s.value = v
+	s.mu.Unlock()
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	s := g.get(labelValues, nil)
+	s.mu.Lock()
+	s.value += v
+	s.mu.Unlock()
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	writeValues(w, &g.vec)
+}
+
+func writeValues(w *bufio.Writer, v *vec) {
+	ss := v.sorted()
+	if len(ss) == 0 {
+		return
+	}
+	writeHeader(w, v.n, v.help, v.typ)
+	for _, s := range ss {
+		s.mu.Lock()
+		val := s.value
+		s.mu.Unlock()
+		writeSample(w, v.n, v.labels, s.values, "", "", val)
+	}
+}
+
+type gaugeFunc struct {
+	n, help string
+	labels  []string
+	fn      func(set func(v float64, labelValues ...string))
+}
+
+func (g *gaugeFunc) name() string {
+	return g.n
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	type sample struct {
+		values []string
+		v      float64
+	}
+	var samples []sample
+	g.fn(func(v float64, labelValues ...string) {
+		if len(labelValues) == len(g.labels) {
+			samples = append(samples, sample{values: labelValues, v: v})
+		}
+	})
+	if len(samples) == 0 {
+		return
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].values, labelSep) < strings.Join(samples[j].values, labelSep)
+	})
+	writeHeader(w, g.n, g.help, typeGauge)
+	for _, s := range samples {
+		writeSample(w, g.n, g.labels, s.values, "", "", s.v)
+	}
+}
+
+// Histogram 直方图
+type Histogram struct {
+	vec
+	buckets []float64
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	s := h.get(labelValues, func(s *series) {
+		s.counts = make([]uint64, len(h.buckets)+1)
+	})
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.mu.Lock()
+	s.counts[i]++
+	s.sum += v
+	s.count++
+	s.mu.Unlock()
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	ss := h.sorted()
+	if len(ss) == 0 {
+		return
+	}
+	writeHeader(w, h.n, h.help, h.typ)
+	for _, s := range ss {
+		s.mu.Lock()
+		counts := append([]uint64(nil), s.counts...)
+		sum, count := s.sum, s.count
+		s.mu.Unlock()
+		var cum uint64
+		for i, b := range h.buckets {
+			cum += counts[i]
+			writeSample(w, h.n+"_bucket", h.labels, s.values, "le", formatFloat(b), float64(cum))
+		}
+		writeSample(w, h.n+"_bucket", h.labels, s.values, "le", "+Inf", float64(count))
+		writeSample(w, h.n+"_sum", h.labels, s.values, "", "", sum)
+		writeSample(w, h.n+"_count", h.labels, s.values, "", "", float64(count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, l, escapeLabel(values[i]))
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
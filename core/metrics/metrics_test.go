@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_requests_total", "requests", "route", "status")
+	c.Inc("/a", "200")
+	c.Add(2, "/a", "200")
+	c.Inc(`/b"x`, "500")
+	if r.NewCounter("test_requests_total", "requests", "route", "status") != c {
+		t.Error("duplicate register should return the same counter")
+	}
+	g := r.NewGauge("test_up", "")
+	g.Set(1)
+	h := r.NewHistogram("test_duration_seconds", "duration", []float64{0.1, 1}, "op")
+	h.Observe(0.05, "select")
+	h.Observe(0.1, "select")
+	h.Observe(3, "select")
+	r.NewGaugeFunc("test_nodes", "nodes", []string{"service"}, func(set func(v float64, labelValues ...string)) {
+		set(2, "user")
+		set(1, "order")
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	want := `# HELP test_duration_seconds duration
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{op="select",le="0.1"} 2
+test_duration_seconds_bucket{op="select",le="1"} 2
+test_duration_seconds_bucket{op="select",le="+Inf"} 3
+test_duration_seconds_sum{op="select"} 3.15
+test_duration_seconds_count{op="select"} 3
+# HELP test_nodes nodes
+# TYPE test_nodes gauge
+test_nodes{service="order"} 1
+test_nodes{service="user"} 2
+# HELP test_requests_total requests
+# TYPE test_requests_total counter
+test_requests_total{route="/a",status="200"} 3
+test_requests_total{route="/b\"x",status="500"} 1
+# TYPE test_up gauge
+test_up 1
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type %s", ct)
+	}
+}
+
+func TestRegistryTypeConflict(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("conflict", "")
+	defer func() {
+		if recover() == nil {
+			t.Error("register with another type should panic")
+		}
+	}()
+	r.NewGauge("conflict", "")
+}
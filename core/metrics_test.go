@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/core/cache"
+	"github.com/mooncake9527/npx/core/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MetricsMiddleware())
+	r.GET("/user/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/metrics", gin.WrapH(metrics.Default.Handler()))
+	for _, path := range []string{"/user/1", "/user/2", "/none"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	promDbMetrics{}.ObserveSql(SqlRecord{Fingerprint: "SELECT * FROM `user` WHERE id = ?", Elapsed: time.Millisecond, Slow: true})
+	promDbMetrics{}.ObserveSql(SqlRecord{Fingerprint: "UPDATE `user` SET name = ?", Err: ErrRecordNotFound})
+
+	Cache = cache.NewMemory()
+	defer func() {
+		Cache = nil
+		cache.SetObserver(nil)
+	}()
+	cache.SetObserver(func(op string, hit bool) {
+		result := "miss"
+		if hit {
+			result = "hit"
+		}
+		cacheRequests.Inc(Cache.Type(), op, result)
+	})
+	_ = Cache.Set("k", "v", time.Minute)
+	_, _ = Cache.Get("k")
+	_, _ = Cache.Get("none")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`npx_http_requests_total{method="GET",route="/user/:id",status="200"} 2`,
+		`npx_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`npx_http_request_duration_seconds_count{method="GET",route="/user/:id"} 2`,
+		`npx_db_query_duration_seconds_count{op="select"} 1`,
+		`npx_db_query_errors_total{op="update"} 1`,
+		`npx_db_slow_queries_total{op="select"} 1`,
+		`npx_cache_requests_total{type="memory",op="get",result="hit"} 1`,
+		`npx_cache_requests_total{type="memory",op="get",result="miss"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s in\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsRestart(t *testing.T) {
+	ResetState()
+	defer ResetState()
+	Cache = cache.NewMemory()
+	for i := 0; i < 2; i++ {
+		if err := initMetrics(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, _ := ginEngine()
+	if n := len(r.Handlers); n != 1 {
+		t.Errorf("middlewares = %d, want 1", n)
+	}
+	dbMetricsLock.RLock()
+	n := len(dbMetrics)
+	dbMetricsLock.RUnlock()
+	if n != 1 {
+		t.Errorf("db metrics = %d, want 1", n)
+	}
+
+	if err := stopMetrics(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if hasDbMetrics() {
+		t.Error("db metrics should be removed")
+	}
+	var buf strings.Builder
+	_ = metrics.Default.Write(&buf)
+	if strings.Contains(buf.String(), metricDbPoolConnections) {
+		t.Error("pool gauge should be unregistered")
+	}
+}
+
+func TestSqlOp(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM t":        "select",
+		"insert into t values()": "insert",
+		"WITH x AS (select 1)":   "with",
+		"PRAGMA foreign_keys":    "other",
+	}
+	for sql, want := range cases {
+		if got := sqlOp(sql); got != want {
+			t.Errorf("sqlOp(%q) = %s, want %s", sql, got, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/bsm/redislock"
+	"github.com/mooncake9527/npx/core/locker"
+	"github.com/mooncake9527/npx/core/migrate"
+	"github.com/pkg/errors"
+)
+
+const (
+	migrateLockTTL   = time.Minute
+	migrateLockRetry = 600 //每秒重试，最多等待10分钟或到 ctx 结束
+)
+
+// NewMigrator 获取db的迁移执行器，有 Redis 时使用 Redis 锁，否则使用库内锁表
+func NewMigrator(key string, dryRun bool, out io.Writer) (*migrate.Migrator, error) {
+	db, err := GetDb(key)
+	if err != nil {
+		return nil, err
+	}
+	opts := []migrate.Option{migrate.WithDryRun(dryRun || db.DryRun)}
+	if out != nil {
+		opts = append(opts, migrate.WithOutput(out))
+	}
+	if RedisLock != nil {
+		opts = append(opts, migrate.WithLocker(redisMigrateLocker{RedisLock}))
+	}
+	return migrate.New(key, db, opts...), nil
+}
+
+// Migrate 对所有注册了迁移的db执行未执行的迁移，DBCfg.DryRun 时只输出sql
+func Migrate(ctx context.Context) error {
+	for _, key := range migrate.Keys() {
+		m, err := NewMigrator(key, Cfg.DBCfg.DryRun, nil)
+		if err != nil {
+			return err
+		}
+		done, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(done) > 0 {
+			slog.Info("migrate done", "key", key, "versions", done)
+		}
+	}
+	return nil
+}
+
+// MigrateCommand 迁移命令，需在 Init 之后调用
+//
+//	migrate up|down|status [-db default] [-steps 1] [-dry-run]
+//
+// e.g. if len(os.Args) > 1 && os.Args[1] == "migrate" { core.Init(); err := core.MigrateCommand(os.Args[2:]) }
+func MigrateCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	key := fs.String("db", "", "db key, default all registered")
+	steps := fs.Int("steps", 1, "versions to roll back for down")
+	dryRun := fs.Bool("dry-run", Cfg.DBCfg.DryRun, "print sql only")
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down|status [-db key] [-steps n] [-dry-run]")
+	}
+	action := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	keys := migrate.Keys()
+	if *key != "" {
+		keys = []string{*key}
+	}
+	ctx := context.Background()
+	for _, k := range keys {
+		m, err := NewMigrator(k, *dryRun, os.Stdout)
+		if err != nil {
+			return err
+		}
+		switch action {
+		case "up":
+			done, err := m.Up(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: applied %v\n", k, done)
+		case "down":
+			done, err := m.Down(ctx, *steps)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: rolled back %v\n", k, done)
+		case "status":
+			ss, err := m.Status(ctx)
+			if err != nil {
+				return err
+			}
+			for _, s := range ss {
+				state := "pending"
+				if s.Applied {
+					state = "applied " + s.AppliedAt.Format(time.DateTime)
+				}
+				fmt.Printf("%s\t%d\t%s\t%s\n", k, s.Version, s.Name, state)
+			}
+		default:
+			return errors.Errorf("unknown migrate action %s", action)
+		}
+	}
+	return nil
+}
+
+// redisMigrateLocker Redis 锁，执行期间定期续期，等待锁时 ctx 结束即返回
+type redisMigrateLocker struct {
+	r *locker.Redis
+}
+
+func (l redisMigrateLocker) Lock(ctx context.Context, name string) (func(), error) {
+	lock, err := l.r.LockContext(ctx, name, migrateLockTTL, &redislock.Options{
+		RetryStrategy: redislock.LimitRetry(redislock.LinearBackoff(time.Second), migrateLockRetry),
+	})
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(migrateLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := lock.Refresh(ctx, migrateLockTTL, nil); err != nil {
+					slog.Error("migrate lock refresh err", "name", name, "err", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		if err := lock.Release(context.Background()); err != nil {
+			slog.Error("migrate unlock err", "name", name, "err", err)
+		}
+	}, nil
+}
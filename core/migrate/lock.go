@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	// LockTable 库内锁表
+	LockTable = "npx_migration_locks"
+)
+
+var (
+	// lockStale 超过该时间未释放的锁视为实例异常退出遗留，可被抢占
+	lockStale = 10 * time.Minute
+	// lockHeartbeat 持有期间更新 locked_at 的间隔，需小于 lockStale
+	lockHeartbeat = time.Minute
+	lockRetry     = time.Second
+)
+
+type lockRow struct {
+	Name     string    `gorm:"primaryKey;size:128"`
+	Owner    string    `gorm:"size:64"`
+	LockedAt time.Time ``
+}
+
+func (lockRow) TableName() string {
+	return LockTable
+}
+
+// DbLocker 基于库内锁表的分布式锁，主键冲突即为已被占用，持有期间定期续期
+type DbLocker struct {
+	db *gorm.DB
+}
+
+func NewDbLocker(db *gorm.DB) *DbLocker {
+	return &DbLocker{db: db}
+}
+
+// Lock 锁被占用时等待直到 ctx 结束，其它写入错误（无权限、连接断开等）直接返回
+func (l *DbLocker) Lock(ctx context.Context, name string) (func(), error) {
+	db := l.db.WithContext(ctx)
+	if err := db.AutoMigrate(&lockRow{}); err != nil {
+		return nil, err
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	owner := hex.EncodeToString(b[:])
+	for {
+		db.Where("name = ? AND locked_at < ?", name, time.Now().Add(-lockStale)).Delete(&lockRow{})
+		err := db.Create(&lockRow{Name: name, Owner: owner, LockedAt: time.Now()}).Error
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) && !l.held(db, name) {
+			return nil, errors.Wrapf(err, "migrate lock %s", name)
+		}
+		slog.Info("migrate lock is held, waiting", "name", name)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetry):
+		}
+	}
+	done := make(chan struct{})
+	go l.heartbeat(name, owner, lockHeartbeat, done)
+	return func() {
+		close(done)
+		if err := l.db.Where("name = ? AND owner = ?", name, owner).Delete(&lockRow{}).Error; err != nil {
+			slog.Error("migrate unlock err", "name", name, "err", err)
+		}
+	}, nil
+}
+
+// held 写入失败时锁是否已被其它实例持有，用于区分主键冲突与其它错误
+func (l *DbLocker) held(db *gorm.DB, name string) bool {
+	var n int64
+	return db.Model(&lockRow{}).Where("name = ?", name).Count(&n).Error == nil && n > 0
+}
+
+// heartbeat 定期更新 locked_at，避免执行较久的迁移被视为遗留锁而被抢占
+func (l *DbLocker) heartbeat(name, owner string, interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			res := l.db.Model(&lockRow{}).Where("name = ? AND owner = ?", name, owner).Update("locked_at", time.Now())
+			if res.Error != nil {
+				slog.Error("migrate lock refresh err", "name", name, "err", res.Error)
+			} else if res.RowsAffected == 0 {
+				slog.Error("migrate lock lost", "name", name)
+			}
+		}
+	}
+}
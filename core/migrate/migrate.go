@@ -0,0 +1,404 @@
+// Package migrate 按db标识注册的版本化迁移
+//
+// 迁移按 Version 升序执行，已执行的版本记录在各库的 npx_migrations 表中
+//
+//	migrate.Register("default", migrate.Migration{
+//		Version: 2024060101,
+//		Name:    "create_user",
+//		Up:      func(tx *gorm.DB) error { return tx.AutoMigrate(&User{}) },
+//		Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&User{}) },
+//	})
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	// HistoryTable 迁移记录表
+	HistoryTable = "npx_migrations"
+)
+
+// Migration 一个迁移版本，Up/Down 与 UpSQL/DownSQL 二选一
+type Migration struct {
+	Version uint64 //版本号，按升序执行 e.g. 2024060101
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+	UpSQL   string //多条语句以行尾的 ; 分隔
+	DownSQL string
+}
+
+func (m Migration) up(tx *gorm.DB) error {
+	if m.Up != nil {
+		return m.Up(tx)
+	}
+	return execSQL(tx, m.UpSQL)
+}
+
+func (m Migration) down(tx *gorm.DB) error {
+	if m.Down != nil {
+		return m.Down(tx)
+	}
+	if m.DownSQL == "" {
+		return errors.Errorf("migration %d %s has no down", m.Version, m.Name)
+	}
+	return execSQL(tx, m.DownSQL)
+}
+
+var stmtSepRe = regexp.MustCompile(`;\s*(\r?\n|$)`)
+
+func execSQL(tx *gorm.DB, sql string) error {
+	for _, stmt := range stmtSepRe.Split(sql, -1) {
+		if stmt = strings.TrimSpace(stmt); stmt == "" {
+			continue
+		}
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History 迁移记录
+type History struct {
+	Version   uint64    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255"`
+	AppliedAt time.Time ``
+	Duration  int64     `gorm:"comment:耗时毫秒"`
+}
+
+func (History) TableName() string {
+	return HistoryTable
+}
+
+var (
+	registry = make(map[string][]Migration)
+	regLock  sync.RWMutex
+)
+
+// Register 为db标识注册迁移，版本重复时 panic
+func Register(key string, ms ...Migration) {
+	regLock.Lock()
+	defer regLock.Unlock()
+	for _, m := range ms {
+		for _, old := range registry[key] {
+			if old.Version == m.Version {
+				panic(fmt.Sprintf("migrate: %s version %d registered twice", key, m.Version))
+			}
+		}
+		registry[key] = append(registry[key], m)
+	}
+	sort.Slice(registry[key], func(i, j int) bool {
+		return registry[key][i].Version < registry[key][j].Version
+	})
+}
+
+var sqlFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// RegisterSQL 注册目录下的sql迁移，文件名为 {版本}_{名称}.up.sql / {版本}_{名称}.down.sql
+// e.g. //go:embed migrations/*.sql 后 RegisterSQL("default", fsys, "migrations")
+func RegisterSQL(key string, fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	ms := make(map[uint64]*Migration)
+	for _, e := range entries {
+		sub := sqlFileRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || sub == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(sub[1], 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "migration file %s", e.Name())
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		m, ok := ms[version]
+		if !ok {
+			m = &Migration{Version: version, Name: sub[2]}
+			ms[version] = m
+		}
+		if sub[3] == "up" {
+			m.UpSQL = string(b)
+		} else {
+			m.DownSQL = string(b)
+		}
+	}
+	list := make([]Migration, 0, len(ms))
+	for _, m := range ms {
+		if m.UpSQL == "" {
+			return errors.Errorf("migration %d %s has no up sql", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	Register(key, list...)
+	return nil
+}
+
+// Keys 已注册迁移的db标识
+func Keys() []string {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	keys := make([]string, 0, len(registry))
+	for k := range registry {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Migrations 已注册的迁移，按版本升序
+func Migrations(key string) []Migration {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	return append([]Migration(nil), registry[key]...)
+}
+
+// Reset 清空注册的迁移，用于测试
+func Reset() {
+	regLock.Lock()
+	defer regLock.Unlock()
+	registry = make(map[string][]Migration)
+}
+
+// Locker 分布式锁，保证同一时间只有一个实例执行迁移
+type Locker interface {
+	Lock(ctx context.Context, name string) (unlock func(), err error)
+}
+
+// Migrator 单个db的迁移执行器
+type Migrator struct {
+	key    string
+	db     *gorm.DB
+	locker Locker
+	dryRun bool
+	out    io.Writer
+}
+
+type Option func(m *Migrator)
+
+// WithLocker 设置分布式锁，默认使用库内的锁表
+func WithLocker(l Locker) Option {
+	return func(m *Migrator) {
+		m.locker = l
+	}
+}
+
+// WithDryRun 只输出将执行的sql，不执行也不记录
+func WithDryRun(dryRun bool) Option {
+	return func(m *Migrator) {
+		m.dryRun = dryRun
+	}
+}
+
+// WithOutput dry-run 与执行过程的输出，默认 os.Stdout
+func WithOutput(w io.Writer) Option {
+	return func(m *Migrator) {
+		m.out = w
+	}
+}
+
+func New(key string, db *gorm.DB, opts ...Option) *Migrator {
+	m := &Migrator{key: key, db: db, out: os.Stdout}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.locker == nil {
+		m.locker = NewDbLocker(db)
+	}
+	return m
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   uint64     `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Status 已注册迁移及其执行状态，包含库中已记录但未注册的版本
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Status, 0, len(applied))
+	for _, mg := range Migrations(m.key) {
+		s := Status{Version: mg.Version, Name: mg.Name}
+		if h, ok := applied[mg.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &h.AppliedAt
+			delete(applied, mg.Version)
+		}
+		res = append(res, s)
+	}
+	for _, h := range applied {
+		at := h.AppliedAt
+		res = append(res, Status{Version: h.Version, Name: h.Name, Applied: true, AppliedAt: &at})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+	return res, nil
+}
+
+// Up 执行所有未执行的迁移，返回执行的版本
+func (m *Migrator) Up(ctx context.Context) ([]uint64, error) {
+	return m.run(ctx, func(applied map[uint64]History) []Migration {
+		var pending []Migration
+		for _, mg := range Migrations(m.key) {
+			if _, ok := applied[mg.Version]; !ok {
+				pending = append(pending, mg)
+			}
+		}
+		return pending
+	}, true)
+}
+
+// Down 按版本倒序回滚最近执行的 steps 个迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]uint64, error) {
+	return m.run(ctx, func(applied map[uint64]History) []Migration {
+		ms := Migrations(m.key)
+		var rollback []Migration
+		for i := len(ms) - 1; i >= 0 && len(rollback) < steps; i-- {
+			if _, ok := applied[ms[i].Version]; ok {
+				rollback = append(rollback, ms[i])
+			}
+		}
+		return rollback
+	}, false)
+}
+
+func (m *Migrator) run(ctx context.Context, plan func(applied map[uint64]History) []Migration, up bool) ([]uint64, error) {
+	if !m.dryRun {
+		unlock, err := m.locker.Lock(ctx, "npx:migrate:"+m.key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "migrate %s lock", m.key)
+		}
+		defer unlock()
+	}
+	//加锁后建表，避免多个实例同时创建
+	if err := m.ensureHistory(ctx); err != nil {
+		return nil, err
+	}
+
+	//加锁后重新读取，其他实例可能已执行
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []uint64
+	for _, mg := range plan(applied) {
+		if err = m.apply(ctx, mg, up); err != nil {
+			return done, err
+		}
+		done = append(done, mg.Version)
+	}
+	return done, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, mg Migration, up bool) error {
+	action := "up"
+	if !up {
+		action = "down"
+	}
+	fmt.Fprintf(m.out, "-- migrate %s %s %d %s\n", m.key, action, mg.Version, mg.Name)
+	if m.dryRun {
+		db := m.db.Session(&gorm.Session{DryRun: true, Context: ctx, Logger: &dryRunLogger{out: m.out}})
+		if up {
+			return mg.up(db)
+		}
+		return mg.down(db)
+	}
+	begin := time.Now()
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if up {
+			err = mg.up(tx)
+		} else {
+			err = mg.down(tx)
+		}
+		if err != nil {
+			return err
+		}
+		if !up {
+			return tx.Delete(&History{Version: mg.Version}).Error
+		}
+		return tx.Create(&History{
+			Version:   mg.Version,
+			Name:      mg.Name,
+			AppliedAt: time.Now(),
+			Duration:  time.Since(begin).Milliseconds(),
+		}).Error
+	})
+	if err != nil {
+		return errors.Wrapf(err, "migrate %s %s %d %s", m.key, action, mg.Version, mg.Name)
+	}
+	return nil
+}
+
+func (m *Migrator) ensureHistory(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	if db.Migrator().HasTable(&History{}) {
+		return nil
+	}
+	if m.dryRun {
+		return nil
+	}
+	return db.AutoMigrate(&History{})
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[uint64]History, error) {
+	res := make(map[uint64]History)
+	db := m.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&History{}) {
+		return res, nil
+	}
+	var hs []History
+	if err := db.Order("version").Find(&hs).Error; err != nil {
+		return nil, err
+	}
+	for _, h := range hs {
+		res[h.Version] = h
+	}
+	return res, nil
+}
+
+// dryRunLogger dry-run 时输出生成的sql
+type dryRunLogger struct {
+	logger.Interface
+	out io.Writer
+}
+
+func (l *dryRunLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l *dryRunLogger) Info(context.Context, string, ...interface{})  {}
+func (l *dryRunLogger) Warn(context.Context, string, ...interface{})  {}
+func (l *dryRunLogger) Error(context.Context, string, ...interface{}) {}
+
+func (l *dryRunLogger) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	fmt.Fprintf(l.out, "%s;\n", sql)
+}
@@ -0,0 +1,216 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openDb(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// openLockDb 单连接，避免 sqlite 共享缓存在续期与加锁并发时返回 table is locked
+func openLockDb(t *testing.T, name string) *gorm.DB {
+	db := openDb(t, name)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+func TestMigrator(t *testing.T) {
+	Reset()
+	defer Reset()
+	type user struct {
+		Id   int
+		Name string
+	}
+	Register("m", Migration{
+		Version: 2,
+		Name:    "add_user",
+		UpSQL:   "INSERT INTO users (id, name) VALUES (1, 'a');\nINSERT INTO users (id, name) VALUES (2, 'b');",
+		DownSQL: "DELETE FROM users;",
+	}, Migration{
+		Version: 1,
+		Name:    "create_user",
+		Up:      func(tx *gorm.DB) error { return tx.AutoMigrate(&user{}) },
+		Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&user{}) },
+	})
+	db := openDb(t, "migrator")
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	done, err := New("m", db, WithDryRun(true), WithOutput(&out)).Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || !strings.Contains(out.String(), "INSERT INTO users (id, name) VALUES (1, 'a');") {
+		t.Errorf("dry run %v\n%s", done, out.String())
+	}
+	if db.Migrator().HasTable(&user{}) || db.Migrator().HasTable(&History{}) {
+		t.Error("dry run should not change schema")
+	}
+
+	m := New("m", db, WithOutput(&bytes.Buffer{}))
+	if done, err = m.Up(ctx); err != nil || len(done) != 2 || done[0] != 1 {
+		t.Fatalf("up %v %v", done, err)
+	}
+	var n int64
+	db.Table("users").Count(&n)
+	if n != 2 {
+		t.Errorf("users %d", n)
+	}
+	if done, err = m.Up(ctx); err != nil || len(done) != 0 {
+		t.Errorf("second up should be no-op %v %v", done, err)
+	}
+	ss, err := m.Status(ctx)
+	if err != nil || len(ss) != 2 || !ss[0].Applied || !ss[1].Applied {
+		t.Errorf("status %+v %v", ss, err)
+	}
+
+	if done, err = m.Down(ctx, 1); err != nil || len(done) != 1 || done[0] != 2 {
+		t.Fatalf("down %v %v", done, err)
+	}
+	db.Table("users").Count(&n)
+	if n != 0 {
+		t.Errorf("down should delete users, got %d", n)
+	}
+	if ss, _ = m.Status(ctx); ss[1].Applied {
+		t.Error("version 2 should be pending")
+	}
+
+	Register("m", Migration{Version: 3, Name: "broken", UpSQL: "INSERT INTO missing VALUES (1);"})
+	if done, err = m.Up(ctx); err == nil || len(done) != 1 {
+		t.Errorf("broken migration should stop %v %v", done, err)
+	}
+	if ss, _ = m.Status(ctx); ss[2].Applied {
+		t.Error("failed migration should not be recorded")
+	}
+}
+
+func TestRegisterSQL(t *testing.T) {
+	Reset()
+	defer Reset()
+	fsys := fstest.MapFS{
+		"migrations/002_seed.up.sql":     {Data: []byte("INSERT INTO t VALUES (1);")},
+		"migrations/001_init.up.sql":     {Data: []byte("CREATE TABLE t (id int);")},
+		"migrations/001_init.down.sql":   {Data: []byte("DROP TABLE t;")},
+		"migrations/readme.md":           {Data: []byte("ignored")},
+		"migrations/003_broken.down.sql": {Data: []byte("")},
+	}
+	if err := RegisterSQL("s", fsys, "migrations"); err == nil {
+		t.Error("down without up should fail")
+	}
+	delete(fsys, "migrations/003_broken.down.sql")
+	if err := RegisterSQL("s", fsys, "migrations"); err != nil {
+		t.Fatal(err)
+	}
+	ms := Migrations("s")
+	if len(ms) != 2 || ms[0].Name != "init" || ms[0].DownSQL == "" || ms[1].Version != 2 {
+		t.Errorf("migrations %+v", ms)
+	}
+}
+
+func TestDbLocker(t *testing.T) {
+	stale, retry := lockStale, lockRetry
+	lockRetry = 10 * time.Millisecond
+	defer func() {
+		lockStale, lockRetry = stale, retry
+	}()
+	l := NewDbLocker(openLockDb(t, "locker"))
+	ctx := context.Background()
+	unlock, err := l.Lock(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err = l.Lock(waitCtx, "a"); err == nil {
+		t.Fatal("lock should be held")
+	}
+	unlock()
+	unlock, err = l.Lock(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 遗留的锁超时后可被抢占
+	lockStale = 0
+	takeover, err := l.Lock(ctx, "a")
+	if err != nil {
+		t.Fatalf("stale lock should be taken over %v", err)
+	}
+	takeover()
+	unlock()
+}
+
+func TestDbLockerHeartbeat(t *testing.T) {
+	stale, heartbeat, retry := lockStale, lockHeartbeat, lockRetry
+	lockStale, lockHeartbeat, lockRetry = 100*time.Millisecond, 10*time.Millisecond, 10*time.Millisecond
+	defer func() {
+		lockStale, lockHeartbeat, lockRetry = stale, heartbeat, retry
+	}()
+	db := openLockDb(t, "locker_heartbeat")
+	l := NewDbLocker(db)
+	ctx := context.Background()
+	unlock, err := l.Lock(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	time.Sleep(3 * lockStale)
+	// 持有期间续期，不会被视为遗留锁
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err = l.Lock(waitCtx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("held lock should not be taken over, err %v", err)
+	}
+
+	// 非主键冲突的错误直接返回，不重试
+	denied := errors.New("permission denied")
+	_ = db.Callback().Create().Before("gorm:create").Register("test:deny", func(tx *gorm.DB) {
+		_ = tx.AddError(denied)
+	})
+	defer func() { _ = db.Callback().Create().Remove("test:deny") }()
+	if _, err = l.Lock(ctx, "b"); !errors.Is(err, denied) {
+		t.Fatalf("err = %v, want %v", err, denied)
+	}
+}
+
+type lockerFunc func(ctx context.Context, name string) (func(), error)
+
+func (f lockerFunc) Lock(ctx context.Context, name string) (func(), error) {
+	return f(ctx, name)
+}
+
+func TestHistoryCreatedUnderLock(t *testing.T) {
+	Reset()
+	defer Reset()
+	Register("h", Migration{Version: 1, Name: "noop", UpSQL: "SELECT 1;"})
+	db := openDb(t, "history_lock")
+	var before bool
+	locker := lockerFunc(func(context.Context, string) (func(), error) {
+		before = db.Migrator().HasTable(&History{})
+		return func() {}, nil
+	})
+	if _, err := New("h", db, WithLocker(locker), WithOutput(&bytes.Buffer{})).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if before || !db.Migrator().HasTable(&History{}) {
+		t.Errorf("history table should be created after locking, before %v", before)
+	}
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core/migrate"
+)
+
+func TestMigrateCommand(t *testing.T) {
+	Cfg.DBCfg.Driver = Sqlite.String()
+	Cfg.DBCfg.LogMode = "silent"
+	defer func() {
+		Cfg.DBCfg.Driver = ""
+		Cfg.DBCfg.LogMode = ""
+	}()
+	if err := AddDb("migrate", config.DB{DSN: "file:migrate?mode=memory&cache=shared"}); err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDb("migrate")
+	migrate.Reset()
+	defer migrate.Reset()
+	migrate.Register("migrate", migrate.Migration{Version: 1, Name: "init", UpSQL: "CREATE TABLE m (id int);", DownSQL: "DROP TABLE m;"})
+
+	if err := MigrateCommand([]string{"up", "-dry-run"}); err != nil {
+		t.Fatal(err)
+	}
+	if Db("migrate").Migrator().HasTable("m") {
+		t.Error("dry run should not create table")
+	}
+	if err := MigrateCommand([]string{"up", "-db", "migrate"}); err != nil {
+		t.Fatal(err)
+	}
+	if !Db("migrate").Migrator().HasTable("m") {
+		t.Error("up should create table")
+	}
+	if err := MigrateCommand([]string{"down"}); err != nil {
+		t.Fatal(err)
+	}
+	if Db("migrate").Migrator().HasTable("m") {
+		t.Error("down should drop table")
+	}
+	if err := MigrateCommand([]string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateCommand([]string{"sideways"}); err == nil {
+		t.Error("unknown action should fail")
+	}
+	if err := MigrateCommand([]string{"up", "-db", "none"}); err == nil {
+		t.Error("unknown db should fail")
+	}
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core/ebus"
+	"github.com/mooncake9527/npx/driver/consul"
+	"github.com/mooncake9527/npx/driver/etcd"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// ConfigChange 配置变更事件的参数
+//
+//	ebus.Subscribe(func(ctx context.Context, c ebus.ConfigChanged) error { ... })
+type ConfigChange = ebus.ConfigChanged
+
+// remoteKV 远程配置存储，由 driver 中的 etcd、consul 客户端实现
+type remoteKV interface {
+	GetKey(ctx context.Context, key string) ([]byte, error)
+	WatchKey(ctx context.Context, key string, fn func(value []byte))
+}
+
+var stopRemoteConfig context.CancelFunc
+
+// newRemoteKV endpoint 多个地址以逗号分隔，secret-keyring 为 etcd 的 user:password 或 consul 的 token
+func newRemoteKV(rc config.RemoteCfg) (remoteKV, error) {
+	endpoints := strings.Split(rc.Endpoint, ",")
+	switch rc.Provider {
+	case "etcd":
+		c := clientv3.Config{
+			Endpoints:   endpoints,
+			DialTimeout: 5 * time.Second,
+		}
+		if user, password, ok := strings.Cut(rc.SecretKeyring, ":"); ok {
+			c.Username, c.Password = user, password
+		}
+		return etcd.NewClient(&c)
+	case "consul":
+		return consul.NewClient(&api.Config{
+			Address: endpoints[0],
+			Token:   rc.SecretKeyring,
+		})
+	default:
+		return nil, errors.Errorf("unsupported remote config provider: %s", rc.Provider)
+	}
+}
+
+// loadRemoteConfig 读取远程配置叠加到 Cfg 并监听变化
+func loadRemoteConfig(l *config.Loader) error {
+	kv, err := newRemoteKV(Cfg.Remote)
+	if err != nil {
+		return err
+	}
+	if stopRemoteConfig != nil {
+		stopRemoteConfig()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err = watchRemoteConfig(ctx, l, kv, Cfg.Remote); err != nil {
+		cancel()
+		return err
+	}
+	stopRemoteConfig = cancel
+	return nil
+}
+
+func watchRemoteConfig(ctx context.Context, l *config.Loader, kv remoteKV, rc config.RemoteCfg) error {
+	data, err := kv.GetKey(ctx, rc.Path)
+	if err != nil {
+		return errors.Wrapf(err, "remote config %s", rc.Path)
+	}
+	if err = applyRemoteConfig(l, rc, data, false); err != nil {
+		return err
+	}
+	go kv.WatchKey(ctx, rc.Path, func(value []byte) {
+		if err := applyRemoteConfig(l, rc, value, true); err != nil {
+			slog.Error("remote config err", "path", rc.Path, "err", err)
+		}
+	})
+	return nil
+}
+
+// applyRemoteConfig 远程配置删除时恢复为本地配置，解析或校验失败时保留当前配置
+// 首次加载写入 Cfg，之后的变更只替换 GetCfg 的快照
+func applyRemoteConfig(l *config.Loader, rc config.RemoteCfg, data []byte, notify bool) error {
+	var remote map[string]any
+	if len(data) > 0 {
+		var err error
+		if remote, err = config.Parse(rc.GetConfigType(), data); err != nil {
+			return errors.Wrapf(err, "remote config %s", rc.Path)
+		}
+	}
+	var cfg config.AppCfg
+	if err := l.Merge(remote, &cfg); err != nil {
+		return err
+	}
+	for _, key := range l.Unknown() {
+		slog.Warn("unknown config key", "key", key, "remote", rc.Path)
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	config.SetExtend(cfg.Extends)
+	if !notify {
+		Cfg = cfg
+		return nil
+	}
+	old := *GetCfg()
+	setCfg(cfg)
+	slog.Info("remote config changed", "path", rc.Path)
+	ebus.EventBus.Publish(ebus.EventConfigChanged, ConfigChange{Old: old, New: cfg})
+	publish(ebus.ConfigChanged{Old: old, New: cfg})
+	return nil
+}
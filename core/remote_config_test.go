@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core/ebus"
+)
+
+type fakeKV struct {
+	value   []byte
+	updates chan []byte
+}
+
+func (f *fakeKV) GetKey(context.Context, string) ([]byte, error) {
+	return f.value, nil
+}
+
+func (f *fakeKV) WatchKey(ctx context.Context, _ string, fn func(value []byte)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case v := <-f.updates:
+			fn(v)
+		}
+	}
+}
+
+func TestRemoteConfig(t *testing.T) {
+	old := Cfg
+	defer func() {
+		Cfg = old
+		running.Store(nil)
+	}()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("server:\n  port: 7000\n  name: local\nlogger:\n  level: info\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l := config.NewLoader(file, config.WithEnvPrefix(""))
+	if err := l.Load(&Cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan ConfigChange, 1)
+	fn := func(c ConfigChange) { changes <- c }
+	ebus.EventBus.Subscribe(ebus.EventConfigChanged, fn)
+	defer ebus.EventBus.Unsubscribe(ebus.EventConfigChanged, fn)
+
+	kv := &fakeKV{value: []byte("server:\n  port: 7100\n"), updates: make(chan []byte)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rc := config.RemoteCfg{Path: "/app/config"}
+	if err := watchRemoteConfig(ctx, l, kv, rc); err != nil {
+		t.Fatal(err)
+	}
+	if Cfg.Server.Port != 7100 || Cfg.Server.Name != "local" {
+		t.Fatalf("remote should merge over local %+v", Cfg.Server)
+	}
+
+	kv.updates <- []byte("logger:\n  level: error\n")
+	select {
+	case c := <-changes:
+		if c.Old.Logger.Level != "info" || c.New.Logger.Level != "error" || c.New.Server.Port != 7000 {
+			t.Errorf("change %+v -> %+v", c.Old, c.New)
+		}
+		// 变更只替换快照，不修改 Cfg
+		if GetCfg().Logger.Level != "error" || Cfg.Logger.Level != "info" {
+			t.Errorf("snapshot %s, Cfg %s", GetCfg().Logger.Level, Cfg.Logger.Level)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("config changed not published")
+	}
+
+	// 解析失败时保留当前配置
+	kv.updates <- []byte("logger: [")
+	kv.updates <- nil
+	select {
+	case c := <-changes:
+		if c.New.Logger.Level != "info" {
+			t.Errorf("deleted remote should fall back to local %+v", c.New.Logger)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("config changed not published")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	cases := map[string]slog.Level{
+		"":      slog.LevelInfo,
+		"DEBUG": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	for level, want := range cases {
+		if got := parseLogLevel(level); got != want {
+			t.Errorf("parseLogLevel(%q) = %v, want %v", level, got, want)
+		}
+	}
+}
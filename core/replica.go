@@ -0,0 +1,230 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	callbackWrittenCreate = "npx:written_create"
+	callbackWrittenUpdate = "npx:written_update"
+	callbackWrittenDelete = "npx:written_delete"
+	writtenKey            = "npx:db_written"
+	replicaCheckInterval  = 10 * time.Second
+	replicaCheckTimeout   = 3 * time.Second
+)
+
+type replicaNode struct {
+	index   int //配置中的序号，日志中代替 dsn，避免输出密码
+	weight  int
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+var (
+	replicas    = make(map[string][]*replicaNode)
+	replicaLock sync.RWMutex
+	// replicaChecker 停止副本健康检查的协程，未启动时为 nil
+	replicaChecker context.CancelFunc
+)
+
+type rwSession struct {
+	written atomic.Bool
+}
+
+// WithRWSession 开启读写会话，会话内发生写操作后的读请求走主库
+// gin.Context 无需调用，写标记直接记录在 gin.Context 中
+func WithRWSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKey(writtenKey), &rwSession{})
+}
+
+// MarkWritten 标记当前会话已写，之后的读走主库
+func MarkWritten(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	if s, ok := ctx.Value(ctxKey(writtenKey)).(*rwSession); ok {
+		s.written.Store(true)
+		return
+	}
+	if c, ok := ctx.(*gin.Context); ok {
+		c.Set(writtenKey, true)
+	}
+}
+
+// IsWritten 当前会话是否已写
+func IsWritten(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if s, ok := ctx.Value(ctxKey(writtenKey)).(*rwSession); ok {
+		return s.written.Load()
+	}
+	if c, ok := ctx.(*gin.Context); ok {
+		return c.GetBool(writtenKey)
+	}
+	return false
+}
+
+// initReplicas 打开并设置只读副本
+func initReplicas(key string, rs []config.Replica, driver, prefix string, logMode logger.LogLevel, slow, maxIdle, maxOpen, maxLifetime int, singular, color, ignoreNotFound bool, logWrite io.Writer, dryRun bool) {
+	if len(rs) == 0 {
+		return
+	}
+	setReplicas(key, openReplicas(key, rs, driver, prefix, logMode, slow, maxIdle, maxOpen, maxLifetime, singular, color, ignoreNotFound, logWrite, dryRun)...)
+}
+
+// openReplicas 打开只读副本，连接失败的副本先跳过
+func openReplicas(key string, rs []config.Replica, driver, prefix string, logMode logger.LogLevel, slow, maxIdle, maxOpen, maxLifetime int, singular, color, ignoreNotFound bool, logWrite io.Writer, dryRun bool) []*replicaNode {
+	nodes := make([]*replicaNode, 0, len(rs))
+	for i, r := range rs {
+		db, err := openDb(driver, r.DSN, prefix, logMode, slow, maxIdle, maxOpen, maxLifetime, singular, color, ignoreNotFound, logWrite, dryRun)
+		if err != nil {
+			slog.Error("connect replica err", "key", key, "replica", i, "err", err)
+			continue
+		}
+		registerCallbacks(db)
+		n := &replicaNode{index: i, weight: r.GetWeight(), db: db}
+		n.healthy.Store(true)
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// setReplicas 设置db的只读副本，返回被替换的副本
+func setReplicas(key string, nodes ...*replicaNode) []*replicaNode {
+	replicaLock.Lock()
+	defer replicaLock.Unlock()
+	old := replicas[key]
+	if len(nodes) == 0 {
+		delete(replicas, key)
+	} else {
+		replicas[key] = nodes
+	}
+	if len(nodes) > 0 && replicaChecker == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		replicaChecker = cancel
+		go checkReplicas(ctx)
+	}
+	return old
+}
+
+// stopReplicaChecker 停止副本健康检查，db 组件停止时调用
+func stopReplicaChecker() {
+	replicaLock.Lock()
+	defer replicaLock.Unlock()
+	if replicaChecker != nil {
+		replicaChecker()
+		replicaChecker = nil
+	}
+}
+
+// ReplicaDb 获取读库，按权重随机选择健康副本
+// 无副本、会话已写、按租户分库时返回 nil，由调用方使用主库
+func ReplicaDb(ctx context.Context, name string) *gorm.DB {
+	if IsWritten(ctx) {
+		return nil
+	}
+	if _, ok := resolveTenant(ctx, name); ok {
+		return nil
+	}
+	replicaLock.RLock()
+	nodes := replicas[name]
+	replicaLock.RUnlock()
+	total := 0
+	for _, n := range nodes {
+		if n.healthy.Load() {
+			total += n.weight
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	r := rand.Intn(total)
+	for _, n := range nodes {
+		if !n.healthy.Load() {
+			continue
+		}
+		if r < n.weight {
+			return n.db
+		}
+		r -= n.weight
+	}
+	return nil
+}
+
+// checkReplicas 定期检查副本，不可用时剔除，恢复后重新加入
+func checkReplicas(ctx context.Context) {
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		replicaLock.RLock()
+		all := make(map[string][]*replicaNode, len(replicas))
+		for k, v := range replicas {
+			all[k] = v
+		}
+		replicaLock.RUnlock()
+		for key, nodes := range all {
+			for _, n := range nodes {
+				pingReplica(key, n)
+			}
+		}
+	}
+}
+
+func pingReplica(key string, n *replicaNode) {
+	sqlDB, err := n.db.DB()
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), replicaCheckTimeout)
+		err = sqlDB.PingContext(ctx)
+		cancel()
+	}
+	healthy := err == nil
+	if n.healthy.Swap(healthy) != healthy {
+		if healthy {
+			slog.Info("replica recovered", "key", key, "replica", n.index)
+		} else {
+			slog.Error("replica ejected", "key", key, "replica", n.index, "err", err)
+		}
+	}
+}
+
+// markWritten 写操作后标记会话
+func markWritten(db *gorm.DB) {
+	if db.Error == nil {
+		MarkWritten(db.Statement.Context)
+	}
+}
+
+func registerWrittenCallbacks(db *gorm.DB) {
+	cb := db.Callback()
+	register := func(name string, err error) {
+		if err != nil {
+			slog.Error("register callback err", "name", name, "err", err)
+		}
+	}
+	if cb.Create().Get(callbackWrittenCreate) == nil {
+		register(callbackWrittenCreate, cb.Create().After("gorm:create").Register(callbackWrittenCreate, markWritten))
+	}
+	if cb.Update().Get(callbackWrittenUpdate) == nil {
+		register(callbackWrittenUpdate, cb.Update().After("gorm:update").Register(callbackWrittenUpdate, markWritten))
+	}
+	if cb.Delete().Get(callbackWrittenDelete) == nil {
+		register(callbackWrittenDelete, cb.Delete().After("gorm:delete").Register(callbackWrittenDelete, markWritten))
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mooncake9527/npx/config"
+	"gorm.io/gorm/logger"
+)
+
+func TestReplicaDb(t *testing.T) {
+	initReplicas("rw", []config.Replica{
+		{DSN: "file:replica1?mode=memory&cache=shared", Weight: 1},
+		{DSN: "file:replica2?mode=memory&cache=shared", Weight: 3},
+	}, Sqlite.String(), "", logger.Silent, 0, 1, 1, 1, false, false, false, nil, false)
+	defer setReplicas("rw")
+
+	replicaLock.RLock()
+	nodes := replicas["rw"]
+	replicaLock.RUnlock()
+	if len(nodes) != 2 {
+		t.Fatalf("replicas = %d, want 2", len(nodes))
+	}
+	if replicaChecker == nil {
+		t.Fatal("replica checker should be running")
+	}
+	defer func() {
+		stopReplicaChecker()
+		if replicaChecker != nil {
+			t.Error("replica checker should stop")
+		}
+	}()
+
+	ctx := WithRWSession(context.Background())
+	if ReplicaDb(ctx, "rw") == nil {
+		t.Fatal("read should use replica")
+	}
+
+	// 写后读主库
+	type rwModel struct {
+		Id   int
+		Name string
+	}
+	primary := nodes[0].db
+	if err := primary.AutoMigrate(&rwModel{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx = WithRWSession(context.Background())
+	if err := primary.WithContext(ctx).Create(&rwModel{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ReplicaDb(ctx, "rw") != nil {
+		t.Error("read after write should use primary")
+	}
+
+	// 副本不可用时剔除
+	ctx = WithRWSession(context.Background())
+	sqlDB, _ := nodes[1].db.DB()
+	sqlDB.Close()
+	pingReplica("rw", nodes[1])
+	for i := 0; i < 20; i++ {
+		if db := ReplicaDb(ctx, "rw"); db != nodes[0].db {
+			t.Fatal("ejected replica should not be used")
+		}
+	}
+	sqlDB, _ = nodes[0].db.DB()
+	sqlDB.Close()
+	pingReplica("rw", nodes[0])
+	if ReplicaDb(ctx, "rw") != nil {
+		t.Error("no healthy replica should fallback to primary")
+	}
+
+	ctx = WithRWSession(context.Background())
+	MarkWritten(ctx)
+	if ReplicaDb(ctx, "rw") != nil {
+		t.Error("read after write should use primary")
+	}
+	if ReplicaDb(context.Background(), "none") != nil {
+		t.Error("db without replicas should use primary")
+	}
+}
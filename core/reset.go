@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core/ebus"
+	"github.com/mooncake9527/x/eventbus"
+)
+
+// ResetState 停止组件并清除注册的组件，关闭并移除所有db，清空缓存、锁、引擎与事件总线，用于测试之间隔离全局状态，不修改 Cfg，清除运行中的配置快照与 extend
+func ResetState() {
+	_ = components.Stop(context.Background())
+	components = newComponents()
+	if stopRemoteConfig != nil {
+		stopRemoteConfig()
+		stopRemoteConfig = nil
+	}
+	stopTenantJanitor()
+	stopReplicaChecker()
+	for key := range Dbs() {
+		_ = RemoveDb(key)
+	}
+	lock.Lock()
+	engine = nil
+	metricsEngine = nil
+	lock.Unlock()
+	adminLock.Lock()
+	adminEngine = nil
+	adminLock.Unlock()
+	running.Store(nil)
+	config.SetExtend(nil)
+	Cache = nil
+	RedisLock = nil
+	Locker = nil
+	ebus.EventBus = eventbus.New()
+	//关闭旧总线使其协程退出，不等待未处理完的事件
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = ebus.Default.Close(ctx)
+	ebus.Default = ebus.NewBus()
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Scheduler 定时任务组件，启动后按间隔执行任务，停止时取消任务的 ctx 并等待执行中的任务
+// 同一任务不会并发执行，任务的错误与 panic 只输出日志
+//
+//	s := core.NewScheduler("jobs", core.ComponentDb)
+//	s.Every(time.Minute, "clean", cleanExpired)
+//	_ = core.RegisterComponent(s)
+type Scheduler struct {
+	name string
+	deps []string
+
+	mu     sync.Mutex
+	jobs   []job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup //每次启动新建，停止后不再添加
+}
+
+type job struct {
+	name     string
+	interval time.Duration
+	fn       func(ctx context.Context) error
+}
+
+// NewScheduler deps 为依赖的组件名
+func NewScheduler(name string, deps ...string) *Scheduler {
+	return &Scheduler{name: name, deps: append([]string{ComponentLog}, deps...)}
+}
+
+// Every 添加每隔 interval 执行一次的任务，启动后添加的任务立即开始计时
+func (s *Scheduler) Every(interval time.Duration, name string, fn func(ctx context.Context) error) *Scheduler {
+	if interval <= 0 {
+		panic("scheduler: interval must be positive")
+	}
+	j := job{name: name, interval: interval, fn: fn}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, j)
+	if s.ctx != nil {
+		s.run(j)
+	}
+	return s
+}
+
+func (s *Scheduler) Name() string {
+	return s.name
+}
+
+func (s *Scheduler) DependsOn() []string {
+	return s.deps
+}
+
+func (s *Scheduler) Start(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.wg = &sync.WaitGroup{}
+	for _, j := range s.jobs {
+		s.run(j)
+	}
+	return nil
+}
+
+func (s *Scheduler) run(j job) {
+	ctx, wg := s.ctx, s.wg
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := safeCall(j.fn, ctx); err != nil {
+					slog.Error("scheduler job err", "scheduler", s.name, "job", j.name, "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop 取消任务并等待执行中的任务返回，ctx 结束时不再等待
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.cancel == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.cancel()
+	wg := s.wg
+	s.ctx, s.cancel, s.wg = nil, nil, nil
+	s.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
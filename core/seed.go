@@ -0,0 +1,51 @@
+package core
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/mooncake9527/npx/common/consts"
+	"github.com/mooncake9527/npx/core/fixtures"
+)
+
+// Seed 加载目录下的数据，目录下的文件写入默认db，子目录名为db标识
+// 只写入没有数据的表，已有数据的表跳过，重启不会覆盖开发数据；测试中需要清空重建时使用 fixtures.Load
+//
+//	fixtures/sys_user.yml       -> default
+//	fixtures/demo/sys_dept.json -> demo
+func Seed(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	keys := []string{consts.DbDefault}
+	for _, e := range entries {
+		if e.IsDir() {
+			keys = append(keys, e.Name())
+		}
+	}
+	for i, key := range keys {
+		path := dir
+		if i > 0 {
+			path = filepath.Join(dir, key)
+		}
+		fs, err := fixtures.Read(os.DirFS(path), ".")
+		if err != nil {
+			return err
+		}
+		if len(fs) == 0 {
+			continue
+		}
+		db, err := GetDb(key)
+		if err != nil {
+			return err
+		}
+		tables, err := fixtures.InsertIfEmpty(db, fs...)
+		if err != nil {
+			return err
+		}
+		slog.Info("seed done", "key", key, "tables", tables)
+	}
+	return nil
+}
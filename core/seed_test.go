@@ -0,0 +1,47 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mooncake9527/npx/config"
+)
+
+func TestSeed(t *testing.T) {
+	Cfg.DBCfg.Driver = Sqlite.String()
+	Cfg.DBCfg.LogMode = "silent"
+	defer func() {
+		Cfg.DBCfg.Driver = ""
+		Cfg.DBCfg.LogMode = ""
+	}()
+	if err := AddDb("seed", config.DB{DSN: "file:seed?mode=memory&cache=shared"}); err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDb("seed")
+	db := Db("seed")
+	if err := db.Exec("CREATE TABLE items (id int, name text)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "seed"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "seed", "items.yml"), []byte("- id: 1\n  name: a\n- id: 2\n  name: b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Seed(dir); err != nil {
+		t.Fatal(err)
+	}
+	// 已有数据的表不再写入，也不清空
+	db.Exec("INSERT INTO items VALUES (3, 'dev')")
+	if err := Seed(dir); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.Table("items").Count(&n)
+	if n != 3 {
+		t.Errorf("items %d", n)
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/core/ebus"
+)
+
+// ready 服务是否就绪，启动完成后为 true，开始关闭时为 false
+var ready atomic.Bool
+
+// Ready 服务是否就绪
+func Ready() bool {
+	return ready.Load()
+}
+
+// SetReady 设置就绪状态，e.g. 依赖不可用时暂时摘除流量
+func SetReady(v bool) {
+	ready.Store(v)
+}
+
+// ReadyHandler 就绪探针，就绪时返回 200，否则 503，用于 k8s readinessProbe 或 consul 健康检查
+func ReadyHandler(c *gin.Context) {
+	if !Ready() {
+		c.String(http.StatusServiceUnavailable, "not ready")
+		return
+	}
+	c.String(http.StatusOK, "ok")
+}
+
+// shutdown 优雅关闭
+//  1. 就绪置为 false，组件 PreStop（注销服务发现）
+//  2. 等待 close-wait 秒，让负载均衡摘除流量
+//  3. http 服务与组件 Drain（grpc 流等）同时排空，close-wait 结束后开始计时，最长 shutdown-timeout 秒
+//  4. 逆序停止组件，关闭 db 连接池与 redis
+//  5. 关闭运维接口，关闭过程中仍可查看就绪状态与指标
+//  6. 关闭事件总线，不再接收异步事件，等待已发布的事件处理完成，最长到排空的 shutdown-timeout 截止
+func shutdown(srv, admin *http.Server, quit ebus.AppQuit) error {
+	begin := time.Now()
+	SetReady(false)
+	ebus.EventBus.Publish(ebus.EventApplicationQuit)
+	publish(quit)
+	notify(ToClose)
+
+	sc := GetCfg().Server
+	timeout := time.Duration(sc.GetShutdownTimeout()) * time.Second
+	slog.Info("server shutdown ...", "reason", quit.Reason, "timeout", timeout)
+
+	preCtx, preCancel := context.WithTimeout(context.Background(), timeout)
+	err := components.PreStop(preCtx)
+	preCancel()
+	time.Sleep(time.Second * time.Duration(sc.GetCloseWait()))
+
+	//排空单独计时，不被 close-wait 占用
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() {
+		drained <- components.Drain(ctx)
+	}()
+	if e := srv.Shutdown(ctx); e != nil {
+		err = errors.Join(err, fmt.Errorf("server shutdown: %w", e))
+		_ = srv.Close()
+	}
+	err = errors.Join(err, <-drained, Stop(context.Background()))
+	if admin != nil {
+		_ = admin.Close()
+	}
+	publish(ebus.AppStopped{Elapsed: time.Since(begin), Err: err})
+	if e := ebus.Default.Close(ctx); e != nil {
+		slog.Warn("async event handlers not finished", "err", e)
+	}
+	slog.Info("server exiting", "elapsed", time.Since(begin))
+	return err
+}
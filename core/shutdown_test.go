@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core/ebus"
+)
+
+func TestServeGracefulShutdown(t *testing.T) {
+	old := Cfg
+	defer func() {
+		Cfg = old
+		ResetState()
+	}()
+	ResetState()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	Cfg = config.AppCfg{}
+	Cfg.Logger.Director = t.TempDir()
+	Cfg.Server.Mode = ModeProd.String()
+	Cfg.Server.Host = "127.0.0.1"
+	Cfg.Server.Port = port
+	Cfg.Server.ShutdownTimeout = 1
+	Cfg.Server.CloseWait = 1
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(e string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+			return nil
+		}
+	}
+	var drainLeft time.Duration
+	drain := func(ctx context.Context) error {
+		deadline, _ := ctx.Deadline()
+		drainLeft = time.Until(deadline)
+		return record("drain")(ctx)
+	}
+	_ = RegisterComponent(NewComponent("grpc", nil, record("stop"),
+		WithPreStop(record("pre stop")), WithDrain(drain), WithDependsOn(ComponentLog)))
+	if err := Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	inFlight := make(chan struct{})
+	r := GetGinEngine()
+	r.GET("/ready", ReadyHandler)
+	r.GET("/slow", func(c *gin.Context) {
+		close(inFlight)
+		time.Sleep(300 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	SetEngine(r)
+
+	var quit ebus.AppQuit
+	ebus.Subscribe(func(_ context.Context, e ebus.AppQuit) error {
+		quit = e
+		return nil
+	})
+	ctx, cancel := context.WithCancelCause(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx)
+	}()
+	<-Started
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	if resp, err := http.Get(base + "/ready"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("ready = %v, %v", resp, err)
+	}
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-inFlight
+	cancel(errors.New("signal: terminated"))
+	if got := <-body; got != "done" {
+		t.Fatalf("in-flight request = %q", got)
+	}
+	if Ready() {
+		t.Fatal("ready after shutdown started")
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	if quit.Reason != "signal: terminated" {
+		t.Fatalf("quit reason = %q", quit.Reason)
+	}
+	if want := []string{"pre stop", "drain", "stop"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if len(Dbs()) > 0 || Cache != nil {
+		t.Fatal("components not stopped")
+	}
+	// 关闭后不再接收异步事件
+	ebus.Subscribe(func(context.Context, ebus.AppQuit) error { return nil }, ebus.Async())
+	if err := ebus.Publish(context.Background(), ebus.AppQuit{}); !errors.Is(err, ebus.ErrClosed) {
+		t.Errorf("async publish after shutdown err %v", err)
+	}
+	// close-wait 不占用排空的时间
+	if drainLeft < 900*time.Millisecond {
+		t.Fatalf("drain left %v after close-wait", drainLeft)
+	}
+}
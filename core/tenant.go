@@ -0,0 +1,138 @@
+package core
+
+import (
+	"log/slog"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	callbackTenantCreate = "npx:tenant_create"
+	callbackTenantQuery  = "npx:tenant_query"
+	callbackTenantUpdate = "npx:tenant_update"
+	callbackTenantDelete = "npx:tenant_delete"
+	callbackTenantRow    = "npx:tenant_row"
+	skipTenantKey        = "npx:skip_tenant"
+)
+
+// ErrTenantRequired 开启租户隔离时上下文中没有租户id
+var ErrTenantRequired = errors.New("tenant id required")
+
+// WithoutTenant 跳过租户隔离
+// e.g. db.Scopes(core.WithoutTenant).Find(&list)
+func WithoutTenant(db *gorm.DB) *gorm.DB {
+	return db.Set(skipTenantKey, true)
+}
+
+// TenantFilter 当前语句是否需要租户隔离，返回租户id
+// 需要隔离但上下文中没有租户id时添加 ErrTenantRequired 错误，语句不再执行，只有 IgnoreTenant、WithoutTenant 可以跳过
+func TenantFilter(db *gorm.DB) (string, bool) {
+	if !GetCfg().DBCfg.Tenant.Enable {
+		return "", false
+	}
+	if skip, ok := db.Get(skipTenantKey); ok && skip == true {
+		return "", false
+	}
+	ctx := db.Statement.Context
+	if IsIgnoreTenant(ctx) {
+		return "", false
+	}
+	tenantId := GetTenantId(ctx)
+	if tenantId == "" {
+		_ = db.AddError(ErrTenantRequired)
+		return "", false
+	}
+	return tenantId, true
+}
+
+func registerTenantCallbacks(db *gorm.DB) {
+	cb := db.Callback()
+	register := func(name string, err error) {
+		if err != nil {
+			slog.Error("register callback err", "name", name, "err", err)
+		}
+	}
+	if cb.Create().Get(callbackTenantCreate) == nil {
+		register(callbackTenantCreate, cb.Create().Before("gorm:create").Register(callbackTenantCreate, tenantCreate))
+	}
+	if cb.Query().Get(callbackTenantQuery) == nil {
+		register(callbackTenantQuery, cb.Query().Before("gorm:query").Register(callbackTenantQuery, tenantWhere))
+	}
+	if cb.Row().Get(callbackTenantRow) == nil {
+		register(callbackTenantRow, cb.Row().Before("gorm:row").Register(callbackTenantRow, tenantWhere))
+	}
+	if cb.Update().Get(callbackTenantUpdate) == nil {
+		register(callbackTenantUpdate, cb.Update().Before("gorm:update").Register(callbackTenantUpdate, tenantWriteWhere))
+	}
+	if cb.Delete().Get(callbackTenantDelete) == nil {
+		register(callbackTenantDelete, cb.Delete().Before("gorm:delete").Register(callbackTenantDelete, tenantWriteWhere))
+	}
+}
+
+func tenantField(db *gorm.DB) *schema.Field {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil
+	}
+	tc := GetCfg().DBCfg.Tenant
+	return db.Statement.Schema.LookUpField(tc.GetColumn())
+}
+
+// tenantCreate 创建时填充未设置的租户id
+func tenantCreate(db *gorm.DB) {
+	field := tenantField(db)
+	if field == nil {
+		return
+	}
+	tenantId, ok := TenantFilter(db)
+	if !ok {
+		return
+	}
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			setIfZero(db, field, rv.Index(i), tenantId)
+		}
+	case reflect.Struct:
+		setIfZero(db, field, rv, tenantId)
+	}
+}
+
+// tenantWhere 查询追加租户条件
+func tenantWhere(db *gorm.DB) {
+	field := tenantField(db)
+	if field == nil {
+		return
+	}
+	tenantId, ok := TenantFilter(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenantId},
+	}})
+}
+
+// tenantWriteWhere 更新、删除追加租户条件
+// 没有其它条件时不追加，保留 gorm 对无条件更新/删除的检查
+func tenantWriteWhere(db *gorm.DB) {
+	if tenantField(db) == nil {
+		return
+	}
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok && !db.AllowGlobalUpdate && !hasPrimaryValue(db) {
+		return
+	}
+	tenantWhere(db)
+}
+
+func hasPrimaryValue(db *gorm.DB) bool {
+	if len(db.Statement.Schema.PrimaryFields) == 0 {
+		return false
+	}
+	_, values := schema.GetIdentityFieldValuesMap(db.Statement.Context, db.Statement.ReflectValue, db.Statement.Schema.PrimaryFields)
+	return len(values) > 0
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mooncake9527/npx/common/consts"
+	"github.com/mooncake9527/npx/config"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// TenantResolver 将上下文中的租户映射为db标识，ok 为 false 时不做路由
+type TenantResolver func(ctx context.Context, name string) (key string, ok bool)
+
+type tenantPool struct {
+	tenantId string
+	lastUsed atomic.Int64
+}
+
+var (
+	dbLogWrite     io.Writer = io.Discard
+	tenantResolver TenantResolver
+	tenantPools    = make(map[string]*tenantPool)
+	tenantLock     sync.Mutex
+	// tenantOpening 同一租户库并发打开时只连接一次
+	tenantOpening singleflight.Group
+	// tenantJanitor 停止关闭空闲租户库的协程，未启动时为 nil
+	tenantJanitor context.CancelFunc
+	// tenantIdPattern 租户id会替换到 DSN 中，只允许字母、数字、下划线和横线
+	tenantIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// SetTenantResolver 自定义租户到db标识的映射
+func SetTenantResolver(resolver TenantResolver) {
+	tenantLock.Lock()
+	defer tenantLock.Unlock()
+	tenantResolver = resolver
+}
+
+// defaultTenantResolver 配置了租户库模板时，默认库按租户路由到 tenant_{租户id}
+func defaultTenantResolver(ctx context.Context, name string) (string, bool) {
+	if GetCfg().DBCfg.Tenant.DSN == "" || name != consts.DbDefault || IsIgnoreTenant(ctx) {
+		return "", false
+	}
+	tenantId := GetTenantId(ctx)
+	if tenantId == "" {
+		return "", false
+	}
+	return "tenant_" + tenantId, true
+}
+
+// TenantDb 按上下文中的租户获取db，不需要路由时返回 Db(name)
+// 租户库不存在时按 TenantCfg.DSN 模板打开
+func TenantDb(ctx context.Context, name string) (*gorm.DB, error) {
+	key, ok := resolveTenant(ctx, name)
+	if !ok {
+		return GetDb(name)
+	}
+	if db := lookupTenant(key); db != nil {
+		return db, nil
+	}
+	return openTenantDb(key, GetTenantId(ctx))
+}
+
+func resolveTenant(ctx context.Context, name string) (string, bool) {
+	tenantLock.Lock()
+	resolver := tenantResolver
+	tenantLock.Unlock()
+	if resolver == nil {
+		resolver = defaultTenantResolver
+	}
+	return resolver(ctx, name)
+}
+
+func getDb(key string) *gorm.DB {
+	lock.RLock()
+	defer lock.RUnlock()
+	return dbs[key]
+}
+
+// lookupTenant 查找与更新使用时间在同一把锁内，避免查到的库被同时判定为空闲而关闭
+func lookupTenant(key string) *gorm.DB {
+	tenantLock.Lock()
+	defer tenantLock.Unlock()
+	db := getDb(key)
+	if p, ok := tenantPools[key]; ok && db != nil {
+		p.lastUsed.Store(time.Now().UnixNano())
+	}
+	return db
+}
+
+// openTenantDb 同一租户库只连接一次，连接在锁外进行，避免慢的租户库阻塞其他租户
+func openTenantDb(key, tenantId string) (*gorm.DB, error) {
+	if !tenantIdPattern.MatchString(tenantId) {
+		return nil, errors.Errorf("invalid tenant id %q", tenantId)
+	}
+	v, err, _ := tenantOpening.Do(key, func() (any, error) {
+		if db := lookupTenant(key); db != nil {
+			return db, nil
+		}
+		cfg := *GetCfg()
+		dc, tc := cfg.DBCfg, cfg.DBCfg.Tenant
+		if tc.DSN == "" {
+			return nil, errors.Errorf("tenant db %s not found", key)
+		}
+		driver := tc.Driver
+		if driver == "" {
+			driver = dc.Driver
+		}
+		dsn := strings.NewReplacer("{tenant}", tenantId, "{key}", key).Replace(tc.DSN)
+		db, err := openDb(driver, dsn, dc.Prefix, config.GetLogMode(dc.LogMode), dc.SlowThreshold,
+			dc.GetMaxIdleConn(), dc.GetMaxOpenConn(), dc.GetMaxLifetime(), dc.Singular, cfg.Logger.Color(),
+			dc.IgnoreNotFound, dbLogWrite, dc.DryRun)
+		if err != nil {
+			slog.Error("connect tenant db err", "key", key, "tenant", tenantId, "err", err)
+			return nil, errors.Wrapf(err, "connect tenant db %s", key)
+		}
+		return storeTenantDb(key, tenantId, db), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*gorm.DB), nil
+}
+
+// storeTenantDb 登记新连接的租户库，已被其他途径注册时关闭新连接并返回已有的库
+func storeTenantDb(key, tenantId string, db *gorm.DB) *gorm.DB {
+	tenantLock.Lock()
+	defer tenantLock.Unlock()
+	if exist := getDb(key); exist != nil {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		return exist
+	}
+	SetDb(key, db)
+	p := &tenantPool{tenantId: tenantId}
+	p.lastUsed.Store(time.Now().UnixNano())
+	tenantPools[key] = p
+	if tenantJanitor == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		tenantJanitor = cancel
+		go closeIdleTenants(ctx)
+	}
+	slog.Info("tenant db opened", "key", key, "tenant", tenantId)
+	return db
+}
+
+// closeIdleTenants 定期关闭空闲超时的租户库
+func closeIdleTenants(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			tc := GetCfg().DBCfg.Tenant
+			closeTenantsIdleFor(time.Duration(tc.GetIdleTimeout()) * time.Minute)
+		}
+	}
+}
+
+// stopTenantJanitor 停止关闭空闲租户库的协程，db 组件停止时调用
+func stopTenantJanitor() {
+	tenantLock.Lock()
+	defer tenantLock.Unlock()
+	if tenantJanitor != nil {
+		tenantJanitor()
+		tenantJanitor = nil
+	}
+}
+
+func closeTenantsIdleFor(timeout time.Duration) {
+	deadline := time.Now().Add(-timeout).UnixNano()
+	closed := make(map[string]*gorm.DB)
+	tenantLock.Lock()
+	for key, p := range tenantPools {
+		if p.lastUsed.Load() > deadline {
+			continue
+		}
+		delete(tenantPools, key)
+		lock.Lock()
+		if db := dbs[key]; db != nil {
+			closed[key] = db
+		}
+		delete(dbs, key)
+		lock.Unlock()
+		slog.Info("tenant db closed", "key", key, "tenant", p.tenantId)
+	}
+	tenantLock.Unlock()
+	//排空连接可能较慢，在锁外关闭
+	for key, db := range closed {
+		_ = closeDb(context.Background(), key, db)
+	}
+}
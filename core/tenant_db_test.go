@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mooncake9527/npx/common/consts"
+	"gorm.io/gorm"
+)
+
+func TestTenantDb(t *testing.T) {
+	Cfg.DBCfg.Driver = Sqlite.String()
+	Cfg.DBCfg.LogMode = "silent"
+	Cfg.DBCfg.Tenant.DSN = "file:{key}?mode=memory&cache=shared"
+	defer func() {
+		Cfg.DBCfg.Driver = ""
+		Cfg.DBCfg.LogMode = ""
+		Cfg.DBCfg.Tenant.DSN = ""
+	}()
+
+	ctxA := WithTenantId(context.Background(), "a")
+	ctxB := WithTenantId(context.Background(), "b")
+	a, err := TenantDb(ctxA, consts.DbDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := TenantDb(ctxB, consts.DbDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Fatal("tenants should use different db")
+	}
+	if again, _ := TenantDb(ctxA, consts.DbDefault); again != a {
+		t.Error("tenant db should be reused")
+	}
+	if _, ok := Dbs()["tenant_a"]; !ok {
+		t.Error("tenant db should be registered")
+	}
+	for _, id := range []string{"a?mode=rw", "../a", "a b", strings.Repeat("a", 65)} {
+		if _, err := TenantDb(WithTenantId(context.Background(), id), consts.DbDefault); err == nil {
+			t.Errorf("tenant id %q should be rejected", id)
+		}
+	}
+
+	// 并发打开同一租户库只连接一次
+	ctxC := WithTenantId(context.Background(), "c")
+	var wg sync.WaitGroup
+	opened := make([]*gorm.DB, 8)
+	for i := range opened {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			opened[i], _ = TenantDb(ctxC, consts.DbDefault)
+		}(i)
+	}
+	wg.Wait()
+	for _, db := range opened {
+		if db == nil || db != opened[0] {
+			t.Fatal("concurrent open should share one tenant db")
+		}
+	}
+
+	closeTenantsIdleFor(0)
+	if _, ok := Dbs()["tenant_a"]; ok {
+		t.Error("idle tenant db should be closed")
+	}
+	if reopen, err := TenantDb(ctxA, consts.DbDefault); err != nil || reopen == a {
+		t.Errorf("tenant db should reopen, err %v", err)
+	}
+
+	SetTenantResolver(func(ctx context.Context, name string) (string, bool) {
+		return "shard_" + GetTenantId(ctx), true
+	})
+	defer SetTenantResolver(nil)
+	if _, err := TenantDb(ctxB, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Dbs()["shard_b"]; !ok {
+		t.Error("custom resolver key should be registered")
+	}
+	closeTenantsIdleFor(0)
+
+	if tenantJanitor == nil {
+		t.Error("janitor should be running")
+	}
+	if err := stopDb(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tenantJanitor != nil {
+		t.Error("janitor should stop with db")
+	}
+}
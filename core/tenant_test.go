@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type tenantModel struct {
+	Id       int
+	Name     string
+	TenantId string
+}
+
+func TestTenantCallbacks(t *testing.T) {
+	Cfg.DBCfg.Tenant.Enable = true
+	defer func() { Cfg.DBCfg.Tenant.Enable = false }()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registerCallbacks(db)
+	if err := db.AutoMigrate(&tenantModel{}); err != nil {
+		t.Fatal(err)
+	}
+	ctxA := WithTenantId(context.Background(), "a")
+	ctxB := WithTenantId(context.Background(), "b")
+
+	a := tenantModel{Name: "a1"}
+	if err := db.WithContext(ctxA).Create(&a).Error; err != nil {
+		t.Fatal(err)
+	}
+	if a.TenantId != "a" {
+		t.Errorf("tenant stamp = %s, want a", a.TenantId)
+	}
+	db.WithContext(ctxB).Create(&[]tenantModel{{Name: "b1"}, {Name: "b2"}})
+
+	var list []tenantModel
+	db.WithContext(ctxB).Find(&list)
+	if len(list) != 2 {
+		t.Errorf("tenant b rows = %d, want 2", len(list))
+	}
+	var count int64
+	db.WithContext(ctxA).Model(&tenantModel{}).Count(&count)
+	if count != 1 {
+		t.Errorf("tenant a count = %d, want 1", count)
+	}
+
+	// 其它租户的数据不可更新、删除
+	res := db.WithContext(ctxB).Model(&tenantModel{}).Where("id = ?", a.Id).Update("name", "x")
+	if res.Error != nil || res.RowsAffected != 0 {
+		t.Errorf("cross tenant update affected %d, err %v", res.RowsAffected, res.Error)
+	}
+	res = db.WithContext(ctxB).Delete(&tenantModel{}, a.Id)
+	if res.Error != nil || res.RowsAffected != 0 {
+		t.Errorf("cross tenant delete affected %d, err %v", res.RowsAffected, res.Error)
+	}
+	// 无条件删除仍被 gorm 拦截
+	if err := db.WithContext(ctxB).Delete(&tenantModel{}).Error; err == nil {
+		t.Errorf("delete without where should fail")
+	}
+
+	db.WithContext(IgnoreTenant(ctxA)).Find(&list)
+	if len(list) != 3 {
+		t.Errorf("ignore tenant rows = %d, want 3", len(list))
+	}
+	db.WithContext(ctxA).Scopes(WithoutTenant).Find(&list)
+	if len(list) != 3 {
+		t.Errorf("without tenant rows = %d, want 3", len(list))
+	}
+
+	// 没有租户id时拒绝执行
+	if err := db.WithContext(context.Background()).Find(&list).Error; !errors.Is(err, ErrTenantRequired) {
+		t.Errorf("query without tenant err = %v", err)
+	}
+	if err := db.Create(&tenantModel{Name: "x"}).Error; !errors.Is(err, ErrTenantRequired) {
+		t.Errorf("create without tenant err = %v", err)
+	}
+	res = db.Model(&tenantModel{}).Where("id = ?", a.Id).Update("name", "x")
+	if !errors.Is(res.Error, ErrTenantRequired) || res.RowsAffected != 0 {
+		t.Errorf("update without tenant affected %d, err %v", res.RowsAffected, res.Error)
+	}
+	db.WithContext(IgnoreTenant(context.Background())).Find(&list)
+	if len(list) != 3 {
+		t.Errorf("ignore tenant rows = %d, want 3", len(list))
+	}
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/pkg/errors"
+)
+
+// certReloader 握手时按间隔检查证书文件，变更后重新加载，加载失败时继续使用旧证书
+type certReloader struct {
+	cfg      config.TLSCfg
+	interval time.Duration
+
+	mu      sync.RWMutex
+	current *tls.Config
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(cfg config.TLSCfg) (*certReloader, error) {
+	r := &certReloader{cfg: cfg, interval: time.Duration(cfg.GetReloadInterval()) * time.Second}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if r.current, err = r.load(); err != nil {
+		return nil, err
+	}
+	r.modTime = modTime
+	r.checked = time.Now()
+	return r, nil
+}
+
+// TLSConfig 服务端配置，每次握手使用最新的证书与客户端 CA
+func (r *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tlsVersion(r.cfg.MinVersion),
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config(), nil
+		},
+	}
+}
+
+func (r *certReloader) config() *tls.Config {
+	r.mu.RLock()
+	c, due := r.current, time.Since(r.checked) >= r.interval
+	r.mu.RUnlock()
+	if !due {
+		return c
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) < r.interval {
+		return r.current
+	}
+	r.checked = time.Now()
+	modTime, err := r.latestModTime()
+	if err != nil || modTime.Equal(r.modTime) {
+		return r.current
+	}
+	next, err := r.load()
+	if err != nil {
+		slog.Error("tls reload err", "cert", r.cfg.CertFile, "err", err)
+		return r.current
+	}
+	r.current, r.modTime = next, modTime
+	slog.Info("tls reloaded", "cert", r.cfg.CertFile)
+	return r.current
+}
+
+func (r *certReloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "tls: load key pair")
+	}
+	c := &tls.Config{
+		MinVersion:   tlsVersion(r.cfg.MinVersion),
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{cert},
+	}
+	switch r.cfg.GetClientAuth() {
+	case "request":
+		c.ClientAuth = tls.RequestClientCert
+	case "require":
+		c.ClientAuth = tls.RequireAnyClientCert
+	case "verify":
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if r.cfg.ClientCA != "" {
+		b, err := os.ReadFile(r.cfg.ClientCA)
+		if err != nil {
+			return nil, errors.Wrap(err, "tls: client ca")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("tls: no certificate in client ca %s", r.cfg.ClientCA)
+		}
+		c.ClientCAs = pool
+	}
+	return c, nil
+}
+
+// latestModTime 证书、私钥、客户端 CA 中最新的修改时间
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCA} {
+		if file == "" {
+			continue
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			return latest, errors.Wrap(err, "tls")
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func tlsVersion(v string) uint16 {
+	if v == "1.3" {
+		return tls.VersionTLS13
+	}
+	return tls.VersionTLS12
+}
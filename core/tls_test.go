@@ -0,0 +1,146 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mooncake9527/npx/config"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	signer, signKey := tpl, key
+	if parent == nil {
+		tpl.IsCA = true
+		tpl.BasicConstraintsValid = true
+		tpl.ExtKeyUsage = nil
+	} else {
+		signer, signKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, signer, &key.PublicKey, signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	b, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, c.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	newTestCert(t, "server-1", ca, x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+	if err := os.WriteFile(caFile, ca.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newCertReloader(config.TLSCfg{CertFile: certFile, KeyFile: keyFile, ClientCA: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(req.Proto))
+	}))
+	srv.EnableHTTP2 = true
+	srv.TLS = r.TLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := newTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth)
+	get := func(certs ...tls.Certificate) (string, error) {
+		c := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs, ServerName: "localhost"},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName + " " + resp.Proto, nil
+	}
+
+	if _, err := get(); err == nil {
+		t.Fatal("expected client certificate required")
+	}
+	if got, err := get(newTestCert(t, "other", nil, x509.ExtKeyUsageClientAuth).tlsCert()); err == nil {
+		t.Fatalf("untrusted client certificate accepted %q", got)
+	}
+	got, err := get(client.tlsCert())
+	if err != nil || got != "server-1 HTTP/2.0" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+
+	newTestCert(t, "server-2", ca, x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+	r.interval = 0
+	if got, err = get(client.tlsCert()); err != nil || got != "server-2 HTTP/2.0" {
+		t.Fatalf("after reload got %q, %v", got, err)
+	}
+
+	// request 只请求证书不校验
+	r.cfg.ClientAuth = "request"
+	if c, err := r.load(); err != nil || c.ClientAuth != tls.RequestClientCert {
+		t.Fatalf("request client auth %v, %v", c, err)
+	}
+	r.cfg.ClientAuth = ""
+
+	// 加载失败时继续使用旧证书
+	if err := os.WriteFile(keyFile, []byte("bad"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(keyFile, future.Add(time.Minute), future.Add(time.Minute))
+	if got, err = get(client.tlsCert()); err != nil || got != "server-2 HTTP/2.0" {
+		t.Fatalf("after bad reload got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/common/consts"
+	"github.com/mooncake9527/npx/common/trace"
+	"gorm.io/gorm"
+)
+
+const traceSpanKey = "npx:trace_span"
+
+// initTrace 按配置设置 Exporter 并挂载链路中间件，需在注册业务路由前调用
+func initTrace() error {
+	var exporter trace.Exporter
+	switch Cfg.Trace.Exporter {
+	case "file":
+		file := Cfg.Trace.File
+		if file == "" {
+			file = filepath.Join(Cfg.Logger.Director, "trace.log")
+		}
+		e, err := trace.NewFileExporter(file)
+		if err != nil {
+			slog.Error("trace exporter init err", "file", file, "err", err)
+			return err
+		}
+		exporter = e
+	default:
+		exporter = trace.NewWriterExporter(os.Stdout)
+	}
+	trace.SetExporter(exporter)
+	trace.SetSampleRate(Cfg.Trace.GetSampleRate())
+	r, err := ginEngine()
+	if err != nil {
+		return err
+	}
+	r.Use(TraceMiddleware())
+	return nil
+}
+
+// TraceMiddleware 每个请求一个服务端 span，按 traceparent 请求头继续上游链路
+// 未设置请求id时使用链路id，响应头返回 traceparent
+func TraceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !trace.Enabled() {
+			c.Next()
+			return
+		}
+		method := c.Request.Method
+		ctx := trace.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := trace.Start(ctx, method+" "+c.Request.URL.Path, trace.KindServer)
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(trace.GinKey, span)
+		if c.GetString(consts.ReqId) == "" {
+			c.Set(consts.ReqId, span.Context().TraceId.String())
+		}
+		c.Header(trace.Header, span.Context().Traceparent())
+		defer span.End()
+
+		c.Next()
+
+		if route := c.FullPath(); route != "" {
+			span.SetName(method + " " + route)
+			span.SetAttr("http.route", route)
+		}
+		status := c.Writer.Status()
+		span.SetAttr("http.method", method)
+		span.SetAttr("http.status_code", status)
+		if len(c.Errors) > 0 {
+			span.SetError(c.Errors.Last())
+		} else if status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("http status %d", status))
+		}
+	}
+}
+
+// registerTraceCallbacks 为 gorm 操作记录子 span，仅在上下文中已有链路时生效
+func registerTraceCallbacks(db *gorm.DB) {
+	cb := db.Callback()
+	register := func(name string, err error) {
+		if err != nil {
+			slog.Error("register callback err", "name", name, "err", err)
+		}
+	}
+	if cb.Create().Get("npx:trace_before_create") == nil {
+		register("npx:trace_before_create", cb.Create().Before("gorm:create").Register("npx:trace_before_create", traceBefore("create")))
+		register("npx:trace_after_create", cb.Create().After("gorm:create").Register("npx:trace_after_create", traceAfter))
+	}
+	if cb.Query().Get("npx:trace_before_query") == nil {
+		register("npx:trace_before_query", cb.Query().Before("gorm:query").Register("npx:trace_before_query", traceBefore("query")))
+		register("npx:trace_after_query", cb.Query().After("gorm:query").Register("npx:trace_after_query", traceAfter))
+	}
+	if cb.Update().Get("npx:trace_before_update") == nil {
+		register("npx:trace_before_update", cb.Update().Before("gorm:update").Register("npx:trace_before_update", traceBefore("update")))
+		register("npx:trace_after_update", cb.Update().After("gorm:update").Register("npx:trace_after_update", traceAfter))
+	}
+	if cb.Delete().Get("npx:trace_before_delete") == nil {
+		register("npx:trace_before_delete", cb.Delete().Before("gorm:delete").Register("npx:trace_before_delete", traceBefore("delete")))
+		register("npx:trace_after_delete", cb.Delete().After("gorm:delete").Register("npx:trace_after_delete", traceAfter))
+	}
+	if cb.Row().Get("npx:trace_before_row") == nil {
+		register("npx:trace_before_row", cb.Row().Before("gorm:row").Register("npx:trace_before_row", traceBefore("row")))
+		register("npx:trace_after_row", cb.Row().After("gorm:row").Register("npx:trace_after_row", traceAfter))
+	}
+	if cb.Raw().Get("npx:trace_before_raw") == nil {
+		register("npx:trace_before_raw", cb.Raw().Before("gorm:raw").Register("npx:trace_before_raw", traceBefore("raw")))
+		register("npx:trace_after_raw", cb.Raw().After("gorm:raw").Register("npx:trace_after_raw", traceAfter))
+	}
+}
+
+func traceBefore(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if !trace.Enabled() {
+			return
+		}
+		_, span := trace.StartChild(db.Statement.Context, "gorm "+op, trace.KindClient)
+		if span == nil {
+			return
+		}
+		span.SetAttr("db.system", db.Dialector.Name())
+		span.SetAttr("db.operation", op)
+		if db.Statement.Table != "" {
+			span.SetAttr("db.table", db.Statement.Table)
+		}
+		db.InstanceSet(traceSpanKey, span)
+	}
+}
+
+func traceAfter(db *gorm.DB) {
+	v, ok := db.InstanceGet(traceSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := v.(*trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttr("db.statement", db.Statement.SQL.String())
+	span.SetAttr("db.rows", db.RowsAffected)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.SetError(db.Error)
+	}
+	span.End()
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mooncake9527/npx/common/consts"
+	"github.com/mooncake9527/npx/common/trace"
+	"github.com/mooncake9527/npx/config"
+)
+
+type traceExporter struct {
+	mu    sync.Mutex
+	spans []trace.SpanData
+}
+
+func (e *traceExporter) Export(s trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func TestTraceMiddleware(t *testing.T) {
+	Cfg.DBCfg.Driver = Sqlite.String()
+	Cfg.DBCfg.LogMode = "silent"
+	defer func() {
+		Cfg.DBCfg.Driver = ""
+		Cfg.DBCfg.LogMode = ""
+	}()
+	if err := AddDb("trace", config.DB{DSN: "file:trace?mode=memory&cache=shared"}); err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDb("trace")
+	e := &traceExporter{}
+	trace.SetExporter(e)
+	defer trace.SetExporter(nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TraceMiddleware())
+	var reqId string
+	r.GET("/user/:id", func(c *gin.Context) {
+		reqId = c.GetString(consts.ReqId)
+		var n int
+		Db("trace").WithContext(c).Raw("select 1").Scan(&n)
+		c.Status(http.StatusOK)
+	})
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+	req.Header.Set(trace.Header, parent)
+	r.ServeHTTP(w, req)
+
+	if len(e.spans) != 2 {
+		t.Fatalf("exported %+v", e.spans)
+	}
+	db, srv := e.spans[0], e.spans[1]
+	if srv.Name != "GET /user/:id" || srv.Kind != trace.KindServer || srv.ParentId != "00f067aa0ba902b7" {
+		t.Errorf("server span %+v", srv)
+	}
+	if srv.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || reqId != srv.TraceId {
+		t.Errorf("trace id %s req id %s", srv.TraceId, reqId)
+	}
+	if db.Name != "gorm row" || db.ParentId != srv.SpanId || db.Attrs["db.statement"] != "select 1" {
+		t.Errorf("gorm span %+v", db)
+	}
+	if sc, err := trace.ParseTraceparent(w.Header().Get(trace.Header)); err != nil || sc.SpanId.String() != srv.SpanId {
+		t.Errorf("response traceparent %s", w.Header().Get(trace.Header))
+	}
+
+	// 无链路时不记录sql
+	e.spans = nil
+	var n int
+	Db("trace").Raw("select 1").Scan(&n)
+	if len(e.spans) != 0 {
+		t.Errorf("orphan gorm span exported %+v", e.spans)
+	}
+}
@@ -1,6 +1,7 @@
 package consul
 
 import (
+	"context"
 	"github.com/pkg/errors"
 	"log/slog"
 	"sync"
@@ -122,6 +123,21 @@ func (c *ConsulClient) GetService(name string, clientIp string) (*models.Service
 	return nil, errors.New("no service")
 }
 
+// Services 已发现服务的快照
+func (c *ConsulClient) Services() map[string][]models.ServiceNode {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	res := make(map[string][]models.ServiceNode, len(c.discovered))
+	for name, vs := range c.discovered {
+		nodes := make([]models.ServiceNode, 0, len(vs))
+		for _, v := range vs {
+			nodes = append(nodes, *v)
+		}
+		res[name] = nodes
+	}
+	return res
+}
+
 func (c *ConsulClient) putServiceNode(s *config.DiscoveryNode, entry *api.ServiceEntry) {
 	c.l.Lock()
 	defer c.l.Unlock()
@@ -190,3 +206,40 @@ func (c *ConsulClient) entryToServiceNode(entry *api.ServiceEntry, s *config.Dis
 
 	return &n
 }
+
+// GetKey 读取KV的值，不存在时返回 nil
+func (c *ConsulClient) GetKey(ctx context.Context, key string) ([]byte, error) {
+	pair, _, err := c.client.KV().Get(key, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil || pair == nil {
+		return nil, err
+	}
+	return pair.Value, nil
+}
+
+// WatchKey 阻塞查询监听KV的变化直到 ctx 结束，删除时 fn 收到 nil
+func (c *ConsulClient) WatchKey(ctx context.Context, key string, fn func(value []byte)) {
+	var lastIndex uint64
+	for ctx.Err() == nil {
+		pair, meta, err := c.client.KV().Get(key, (&api.QueryOptions{WaitIndex: lastIndex}).WithContext(ctx))
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("watch key err", "key", key, "err", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		if meta.LastIndex < lastIndex {
+			//索引回退时重新开始
+			lastIndex = 0
+			continue
+		}
+		if lastIndex > 0 && meta.LastIndex != lastIndex {
+			if pair == nil {
+				fn(nil)
+			} else {
+				fn(pair.Value)
+			}
+		}
+		lastIndex = meta.LastIndex
+	}
+}
@@ -117,6 +117,21 @@ func (c *EtcdClient) Watch(s *config.DiscoveryNode) error {
 	return nil
 }
 
+// Services 已发现服务的快照
+func (c *EtcdClient) Services() map[string][]models.ServiceNode {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	res := make(map[string][]models.ServiceNode, len(c.discovered))
+	for name, vs := range c.discovered {
+		nodes := make([]models.ServiceNode, 0, len(vs))
+		for _, v := range vs {
+			nodes = append(nodes, *v)
+		}
+		res[name] = nodes
+	}
+	return res
+}
+
 func (c *EtcdClient) putServiceNode(data []byte, s *config.DiscoveryNode) {
 	c.l.Lock()
 	defer c.l.Unlock()
@@ -160,8 +175,8 @@ func (c *EtcdClient) putServiceNode(data []byte, s *config.DiscoveryNode) {
 }
 
 func (c *EtcdClient) delServiceNode(curId string, s *config.DiscoveryNode) {
-	c.l.RLock()
-	defer c.l.RUnlock()
+	c.l.Lock()
+	defer c.l.Unlock()
 	slog.Debug("-----del ", s.Name, curId)
 	if vs, ok := c.discovered[s.Name]; ok {
 		for i, v := range vs {
@@ -189,3 +204,39 @@ func (c *EtcdClient) GetService(name string, clientIp string) (*models.ServiceNo
 	}
 	return nil, errors.New("no service")
 }
+
+// GetKey 读取key的值，不存在时返回 nil
+func (c *EtcdClient) GetKey(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+// WatchKey 监听key的变化直到 ctx 结束，删除时 fn 收到 nil
+func (c *EtcdClient) WatchKey(ctx context.Context, key string, fn func(value []byte)) {
+	for ctx.Err() == nil {
+		for resp := range c.client.Watch(clientv3.WithRequireLeader(ctx), key) {
+			if err := resp.Err(); err != nil {
+				slog.Error("watch key err", "key", key, "err", err)
+				break
+			}
+			for _, event := range resp.Events {
+				switch event.Type {
+				case mvccpb.PUT:
+					fn(event.Kv.Value)
+				case mvccpb.DELETE:
+					fn(nil)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
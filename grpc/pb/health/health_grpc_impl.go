@@ -6,9 +6,13 @@ import (
 
 type HealthServerImpl struct {
 	*UnimplementedHealthServer
+	Ready func() bool //就绪状态 e.g. core.Ready，关闭时返回 NOT_SERVING，为 nil 时总是 SERVING
 }
 
 func (s *HealthServerImpl) Check(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error) {
+	if s.Ready != nil && !s.Ready() {
+		return &HealthCheckResponse{Status: HealthCheckResponse_NOT_SERVING}, nil
+	}
 	return &HealthCheckResponse{Status: HealthCheckResponse_SERVING}, nil
 }
 
@@ -3,6 +3,7 @@ package models
 import (
 	"fmt"
 
+	"github.com/mooncake9527/npx/common/trace"
 	"github.com/mooncake9527/npx/config"
 	"google.golang.org/grpc"
 )
@@ -46,7 +47,9 @@ func (n *ServiceNode) GetGrpcConn() (conn *grpc.ClientConn, err error) {
 	if n.grpc != nil {
 		conn = n.grpc
 	} else {
-		conn, err = grpc.Dial(fmt.Sprintf("%s:%d", n.Addr, n.Port), grpc.WithInsecure())
+		conn, err = grpc.Dial(fmt.Sprintf("%s:%d", n.Addr, n.Port), grpc.WithInsecure(),
+			grpc.WithChainUnaryInterceptor(trace.UnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(trace.StreamClientInterceptor()))
 		if err == nil {
 			n.grpc = conn
 		}
@@ -0,0 +1,72 @@
+package rd
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/models"
+)
+
+// Component 服务注册与发现组件，可通过 core.RegisterComponent 注册
+// 启动时注册服务并监听发现的服务，关闭时最先注销，之后不再有新流量进入
+type Component struct {
+	cfg          *config.Config
+	deps         []string
+	client       RDClient
+	deregistered atomic.Bool
+}
+
+// NewComponent deps 为依赖的组件名 e.g. rd.NewComponent(cfg, core.ComponentLog)
+func NewComponent(cfg *config.Config, deps ...string) *Component {
+	return &Component{cfg: cfg, deps: deps}
+}
+
+func (c *Component) Name() string {
+	return "discovery"
+}
+
+func (c *Component) DependsOn() []string {
+	return c.deps
+}
+
+func (c *Component) Start(context.Context) error {
+	client, err := NewRDClient(c.cfg)
+	if err != nil {
+		return err
+	}
+	c.client = client
+	c.deregistered.Store(false)
+	return nil
+}
+
+// PreStop 注销服务
+func (c *Component) PreStop(context.Context) error {
+	if c.client != nil && c.deregistered.CompareAndSwap(false, true) {
+		c.client.Deregister()
+	}
+	return nil
+}
+
+// Stop 未注销时注销服务，移除客户端的指标
+func (c *Component) Stop(ctx context.Context) error {
+	err := c.PreStop(ctx)
+	if c.client != nil {
+		removeClient(c.client)
+	}
+	return err
+}
+
+// Client 启动后可用
+func (c *Component) Client() RDClient {
+	return c.client
+}
+
+// Services 已发现的服务，未启动或客户端未实现 ServiceLister 时为 nil
+func (c *Component) Services() map[string][]models.ServiceNode {
+	lister, ok := c.client.(ServiceLister)
+	if !ok {
+		return nil
+	}
+	return lister.Services()
+}
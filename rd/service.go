@@ -2,9 +2,12 @@ package rd
 
 import (
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core/metrics"
 	"github.com/mooncake9527/npx/driver/consul"
 	"github.com/mooncake9527/npx/driver/etcd"
 	"github.com/mooncake9527/npx/models"
@@ -13,6 +16,8 @@ import (
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+const metricDiscoveryNodes = "npx_discovery_nodes"
+
 type RDClient interface {
 	Register(s *config.RegisterNode) error
 	Deregister()
@@ -20,9 +25,14 @@ type RDClient interface {
 	GetService(name string, clientIp string) (*models.ServiceNode, error)
 }
 
+// ServiceLister 可列出已发现服务的客户端，内置的 etcd、consul 客户端已实现
+type ServiceLister interface {
+	Services() map[string][]models.ServiceNode
+}
+
+// NewRDClient 创建客户端，注册服务并监听发现的服务，实现 ServiceLister 的客户端按创建序号统计 npx_discovery_nodes 指标
 func NewRDClient(cfg *config.Config) (client RDClient, err error) {
-	if cfg.Driver != "etcd" && cfg.Driver != "consul" {
-		err = fmt.Errorf("unsupported driver: %s", cfg.Driver)
+	if err = cfg.Validate(); err != nil {
 		return nil, err
 	}
 	if cfg.Driver == "etcd" {
@@ -43,15 +53,6 @@ func NewRDClient(cfg *config.Config) (client RDClient, err error) {
 		return
 	}
 	for _, rs := range cfg.Registers {
-		if rs.Addr == "" || rs.Port <= 0 || rs.Port > 65535 {
-			panic("register node addr or port is error")
-		}
-		if rs.Protocol != "http" && rs.Protocol != "grpc" {
-			panic("register node protocol is error")
-		}
-		if rs.Name == "" {
-			panic("register node name is error")
-		}
 		if rs.Id == "" {
 			rs.Id = fmt.Sprintf("%s:%d", rs.Addr, rs.Port)
 		}
@@ -77,5 +78,78 @@ func NewRDClient(cfg *config.Config) (client RDClient, err error) {
 			}
 		}
 	}
+	addClient(client, cfg.Discoveries)
 	return
 }
+
+// rdClient NewRDClient 创建的客户端，指标按客户端分别统计
+type rdClient struct {
+	id     string
+	lister ServiceLister
+	ds     []*config.DiscoveryNode
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   []*rdClient
+	clientSeq int
+)
+
+// addClient 登记客户端并注册指标，客户端未实现 ServiceLister 时不统计
+func addClient(client RDClient, ds []*config.DiscoveryNode) {
+	lister, ok := client.(ServiceLister)
+	if !ok {
+		return
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clientSeq++
+	clients = append(clients, &rdClient{id: strconv.Itoa(clientSeq), lister: lister, ds: ds})
+	if len(clients) == 1 {
+		registerMetrics()
+	}
+}
+
+// removeClient 移除客户端的指标，没有客户端时注销指标
+func removeClient(client RDClient) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	for i, c := range clients {
+		if any(c.lister) == any(client) {
+			clients = append(clients[:i], clients[i+1:]...)
+			break
+		}
+	}
+	if len(clients) == 0 {
+		metrics.Default.Unregister(metricDiscoveryNodes)
+	}
+}
+
+// registerMetrics 按客户端与 DiscoveryNode.Name 统计已发现的节点数，client 为客户端的创建序号
+func registerMetrics() {
+	metrics.Default.Unregister(metricDiscoveryNodes)
+	metrics.Default.NewGaugeFunc(metricDiscoveryNodes, "Discovered service nodes by client, service and enable state.", []string{"client", "service", "enable"},
+		func(set func(v float64, labelValues ...string)) {
+			clientsMu.Lock()
+			cs := append([]*rdClient(nil), clients...)
+			clientsMu.Unlock()
+			for _, c := range cs {
+				services := c.lister.Services()
+				for _, d := range c.ds {
+					if !d.Enable {
+						continue
+					}
+					enabled, disabled := 0, 0
+					for _, n := range services[d.Name] {
+						if n.Enable() {
+							enabled++
+						} else {
+							disabled++
+						}
+					}
+					set(float64(enabled), c.id, d.Name, "true")
+					set(float64(disabled), c.id, d.Name, "false")
+				}
+			}
+		})
+}
@@ -0,0 +1,44 @@
+package rd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mooncake9527/npx/config"
+	"github.com/mooncake9527/npx/core/metrics"
+	"github.com/mooncake9527/npx/models"
+)
+
+type fakeClient struct {
+	RDClient
+	nodes int
+}
+
+func (c *fakeClient) Services() map[string][]models.ServiceNode {
+	return map[string][]models.ServiceNode{"user": make([]models.ServiceNode, c.nodes)}
+}
+
+func TestClientMetrics(t *testing.T) {
+	ds := []*config.DiscoveryNode{{Name: "user", Enable: true}}
+	a, b := &fakeClient{nodes: 1}, &fakeClient{nodes: 2}
+	addClient(a, ds)
+	addClient(b, ds)
+	write := func() string {
+		var buf bytes.Buffer
+		_ = metrics.Default.Write(&buf)
+		return buf.String()
+	}
+	out := write()
+	for id, n := range map[string]string{clients[0].id: "1", clients[1].id: "2"} {
+		want := `npx_discovery_nodes{client="` + id + `",service="user",enable="false"} ` + n
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s\n%s", want, out)
+		}
+	}
+	removeClient(a)
+	removeClient(b)
+	if strings.Contains(write(), metricDiscoveryNodes) {
+		t.Error("metrics should be unregistered without clients")
+	}
+}